@@ -0,0 +1,367 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/format"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/processor"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/redact"
+)
+
+const (
+	// DefaultBatchSize is the default batch size
+	DefaultBatchSize = 1024
+	// DefaultQuarantinePrefix is the default prefix of quarantine objects.
+	DefaultQuarantinePrefix = "quarantine/"
+	// DefaultRealtimeLogGroup is the default log group for real-time logs.
+	DefaultRealtimeLogGroup = "/cloudfront/realtime"
+	// DefaultConcurrency is the default amount of objects processed at once.
+	DefaultConcurrency = 4
+	// DefaultLedgerLease is the default duration objects are leased for while they are ingested.
+	DefaultLedgerLease = 15 * time.Minute
+	// DefaultUserAgentCacheSize is the default amount of parsed user agents to keep.
+	DefaultUserAgentCacheSize = 10000
+)
+
+// Config for processing CloudFront logs.
+type Config struct {
+	// BatchSize is the amount of events to keep before flushing to CloudWatch Logs.
+	BatchSize int
+	// Concurrency is the amount of objects processed at once.
+	Concurrency int
+	// Format of the logs, or the format used when it can't be detected.
+	Format format.Format
+	// DetectFormat detects the format of each object.
+	DetectFormat bool
+	// Output is the format of the messages pushed to CloudWatch Logs.
+	Output processor.Output
+	// Decode URL-encoded fields such as the user agent and query string.
+	Decode bool
+	// RealtimeFields are the fields selected by the real-time log configuration, in order.
+	RealtimeFields []string
+	// RealtimeLogGroup is the log group real-time logs are pushed to.
+	RealtimeLogGroup string
+	// RealtimeLogGroups are the log groups real-time logs are pushed to by host, instead of the real-time log group.
+	RealtimeLogGroups map[string]string
+	// PartitionLayout of the s3 object keys, eg. {DistributionId}/{yyyy}/{MM}/{dd}/{HH}, overriding the default layout of
+	// the format.
+	PartitionLayout string
+	// HivePartitions removes Hive-compatible partitions (eg. year=2024) from the log group without a partition layout.
+	HivePartitions bool
+	// Timestamp policy for each event.
+	Timestamp processor.TimestampPolicy
+	// Unparseable policy for lines which can't be parsed.
+	Unparseable processor.UnparseablePolicy
+	// Quarantine destination for lines which can't be parsed.
+	Quarantine QuarantineDestination
+	// QuarantineBucket is the bucket quarantine objects are written to, which is required to quarantine lines to s3.
+	QuarantineBucket string
+	// QuarantinePrefix is prepended to the key of the logs to create the key of quarantine objects.
+	QuarantinePrefix string
+	// EnrichUserAgent adds fields describing the browser, OS and device of the user agent.
+	EnrichUserAgent bool
+	// UserAgentRegexes is the path of a uap-core regexes.yaml database, defaulting to the bundled database.
+	UserAgentRegexes string
+	// UserAgentCacheSize is the amount of parsed user agents to keep.
+	UserAgentCacheSize int
+	// GeoIPDatabase is the path of a MaxMind City or Country database used to locate client IPs.
+	GeoIPDatabase string
+	// ASNDatabase is the path of a MaxMind ASN database used to find the network of client IPs.
+	ASNDatabase string
+	// EnrichPOP adds fields describing the city, country and continent of the edge location.
+	EnrichPOP bool
+	// POPTable is the path of a table of edge locations, defaulting to the bundled table.
+	POPTable string
+	// POPSummary pushes a summary of the requests served by each edge location.
+	POPSummary bool
+	// IncludeFields are the only fields which are pushed, or all fields if empty.
+	IncludeFields []string
+	// ExcludeFields are fields which aren't pushed.
+	ExcludeFields []string
+	// RedactionRules is the path of a YAML file of rules for redacting sensitive values.
+	RedactionRules string
+	// Anonymisation of client IPs, unless overridden for the log group.
+	Anonymisation redact.Mode
+	// AnonymisationLogGroups overrides the anonymisation of client IPs for log groups.
+	AnonymisationLogGroups map[string]redact.Mode
+	// AnonymisationKey is the key used to anonymise client IPs with a hmac.
+	AnonymisationKey string
+	// FilterRules is the path of a YAML file of rules which decide whether events are pushed.
+	FilterRules string
+	// SamplingRules is the path of a YAML file of rules which decide the fraction of events which are pushed.
+	SamplingRules string
+	// LedgerTable is the DynamoDB table used to ingest each object only once, or empty to ingest every notification.
+	LedgerTable string
+	// LedgerLease is how long an object is leased for while it is ingested, after which another invocation can retry it.
+	LedgerLease time.Duration
+}
+
+// QuarantineDestination is where lines which can't be parsed are sent.
+type QuarantineDestination string
+
+const (
+	// QuarantineStream sends lines to a separate log stream in the log group.
+	QuarantineStream QuarantineDestination = "stream"
+	// QuarantineS3 writes lines to an s3 object.
+	QuarantineS3 QuarantineDestination = "s3"
+)
+
+// Validate the quarantine destination.
+func (d QuarantineDestination) Validate() error {
+	switch d {
+	case QuarantineStream, QuarantineS3:
+		return nil
+	}
+
+	return fmt.Errorf("unsupported quarantine destination: %s", d)
+}
+
+// Load the configuration from the environment.
+func Load() (Config, error) {
+	config := Config{
+		BatchSize:          DefaultBatchSize,
+		Concurrency:        DefaultConcurrency,
+		Output:             processor.OutputText,
+		RealtimeLogGroup:   DefaultRealtimeLogGroup,
+		Timestamp:          processor.TimestampEnd,
+		Unparseable:        processor.UnparseableQuarantine,
+		Quarantine:         QuarantineStream,
+		QuarantinePrefix:   DefaultQuarantinePrefix,
+		UserAgentCacheSize: DefaultUserAgentCacheSize,
+		Anonymisation:      redact.ModeNone,
+		LedgerLease:        DefaultLedgerLease,
+	}
+
+	if batchSize := os.Getenv("BATCH_SIZE"); batchSize != "" {
+		size, err := strconv.Atoi(batchSize)
+		if err != nil {
+			return config, fmt.Errorf("failed to parse BATCH_SIZE: %w", err)
+		}
+		config.BatchSize = size
+	}
+
+	if concurrency := os.Getenv("CONCURRENCY"); concurrency != "" {
+		amount, err := strconv.Atoi(concurrency)
+		if err != nil {
+			return config, fmt.Errorf("failed to parse CONCURRENCY: %w", err)
+		}
+		config.Concurrency = amount
+	}
+
+	if name := os.Getenv("LOG_FORMAT"); name != "" {
+		logFormat, err := format.Get(name)
+		if err != nil {
+			return config, err
+		}
+		config.Format = logFormat
+	}
+
+	if detect := os.Getenv("DETECT_LOG_FORMAT"); detect != "" {
+		enabled, err := strconv.ParseBool(detect)
+		if err != nil {
+			return config, fmt.Errorf("failed to parse DETECT_LOG_FORMAT: %w", err)
+		}
+		config.DetectFormat = enabled
+	}
+
+	if output := os.Getenv("OUTPUT_FORMAT"); output != "" {
+		config.Output = processor.Output(output)
+	}
+
+	if decode := os.Getenv("URL_DECODE"); decode != "" {
+		enabled, err := strconv.ParseBool(decode)
+		if err != nil {
+			return config, fmt.Errorf("failed to parse URL_DECODE: %w", err)
+		}
+		config.Decode = enabled
+	}
+
+	if fields := os.Getenv("REALTIME_FIELDS"); fields != "" {
+		config.RealtimeFields = splitList(fields)
+	}
+
+	if group := os.Getenv("REALTIME_LOG_GROUP"); group != "" {
+		config.RealtimeLogGroup = group
+	}
+
+	if groups := os.Getenv("REALTIME_LOG_GROUPS"); groups != "" {
+		config.RealtimeLogGroups = make(map[string]string)
+
+		for _, item := range splitList(groups) {
+			host, group, ok := strings.Cut(item, "=")
+			if !ok {
+				return config, fmt.Errorf("failed to parse REALTIME_LOG_GROUPS: %s is not a host=log group pair", item)
+			}
+			config.RealtimeLogGroups[strings.TrimSpace(host)] = strings.TrimSpace(group)
+		}
+	}
+
+	config.PartitionLayout = os.Getenv("PARTITION_LAYOUT")
+
+	if hive := os.Getenv("HIVE_PARTITIONS"); hive != "" {
+		enabled, err := strconv.ParseBool(hive)
+		if err != nil {
+			return config, fmt.Errorf("failed to parse HIVE_PARTITIONS: %w", err)
+		}
+		config.HivePartitions = enabled
+	}
+
+	if timestamp := os.Getenv("TIMESTAMP_POLICY"); timestamp != "" {
+		config.Timestamp = processor.TimestampPolicy(timestamp)
+	}
+
+	if unparseable := os.Getenv("UNPARSEABLE_POLICY"); unparseable != "" {
+		config.Unparseable = processor.UnparseablePolicy(unparseable)
+	}
+
+	if quarantine := os.Getenv("QUARANTINE_DESTINATION"); quarantine != "" {
+		config.Quarantine = QuarantineDestination(quarantine)
+	}
+
+	config.QuarantineBucket = os.Getenv("QUARANTINE_BUCKET")
+
+	if prefix, ok := os.LookupEnv("QUARANTINE_PREFIX"); ok {
+		config.QuarantinePrefix = prefix
+	}
+
+	if enrich := os.Getenv("ENRICH_USER_AGENT"); enrich != "" {
+		enabled, err := strconv.ParseBool(enrich)
+		if err != nil {
+			return config, fmt.Errorf("failed to parse ENRICH_USER_AGENT: %w", err)
+		}
+		config.EnrichUserAgent = enabled
+	}
+
+	config.UserAgentRegexes = os.Getenv("USER_AGENT_REGEXES")
+
+	if cacheSize := os.Getenv("USER_AGENT_CACHE_SIZE"); cacheSize != "" {
+		size, err := strconv.Atoi(cacheSize)
+		if err != nil {
+			return config, fmt.Errorf("failed to parse USER_AGENT_CACHE_SIZE: %w", err)
+		}
+		config.UserAgentCacheSize = size
+	}
+
+	config.GeoIPDatabase = os.Getenv("GEOIP_DATABASE")
+	config.ASNDatabase = os.Getenv("ASN_DATABASE")
+
+	if enrich := os.Getenv("ENRICH_POP"); enrich != "" {
+		enabled, err := strconv.ParseBool(enrich)
+		if err != nil {
+			return config, fmt.Errorf("failed to parse ENRICH_POP: %w", err)
+		}
+		config.EnrichPOP = enabled
+	}
+
+	config.POPTable = os.Getenv("POP_TABLE")
+
+	if summary := os.Getenv("POP_SUMMARY"); summary != "" {
+		enabled, err := strconv.ParseBool(summary)
+		if err != nil {
+			return config, fmt.Errorf("failed to parse POP_SUMMARY: %w", err)
+		}
+		config.POPSummary = enabled
+	}
+
+	if fields := os.Getenv("INCLUDE_FIELDS"); fields != "" {
+		config.IncludeFields = splitList(fields)
+	}
+
+	if fields := os.Getenv("EXCLUDE_FIELDS"); fields != "" {
+		config.ExcludeFields = splitList(fields)
+	}
+
+	config.RedactionRules = os.Getenv("REDACTION_RULES")
+
+	if mode := os.Getenv("IP_ANONYMISATION"); mode != "" {
+		config.Anonymisation = redact.Mode(mode)
+	}
+
+	if groups := os.Getenv("IP_ANONYMISATION_LOG_GROUPS"); groups != "" {
+		config.AnonymisationLogGroups = make(map[string]redact.Mode)
+
+		for _, item := range splitList(groups) {
+			group, mode, ok := strings.Cut(item, "=")
+			if !ok {
+				return config, fmt.Errorf("failed to parse IP_ANONYMISATION_LOG_GROUPS: %s is not a log group=mode pair", item)
+			}
+			config.AnonymisationLogGroups[strings.TrimSpace(group)] = redact.Mode(strings.TrimSpace(mode))
+		}
+	}
+
+	config.AnonymisationKey = os.Getenv("IP_HMAC_KEY")
+
+	config.FilterRules = os.Getenv("FILTER_RULES")
+	config.SamplingRules = os.Getenv("SAMPLING_RULES")
+
+	config.LedgerTable = os.Getenv("LEDGER_TABLE")
+
+	if lease := os.Getenv("LEDGER_LEASE"); lease != "" {
+		duration, err := time.ParseDuration(lease)
+		if err != nil {
+			return config, fmt.Errorf("failed to parse LEDGER_LEASE: %w", err)
+		}
+		config.LedgerLease = duration
+	}
+
+	if config.Concurrency < 1 {
+		return config, fmt.Errorf("CONCURRENCY must be at least 1")
+	}
+
+	if err := config.Output.Validate(); err != nil {
+		return config, err
+	}
+
+	if err := config.Timestamp.Validate(); err != nil {
+		return config, err
+	}
+
+	if err := config.Unparseable.Validate(); err != nil {
+		return config, err
+	}
+
+	if err := config.Quarantine.Validate(); err != nil {
+		return config, err
+	}
+
+	// Quarantine objects written to the bucket of the logs would be ingested as logs themselves.
+	if config.Unparseable == processor.UnparseableQuarantine && config.Quarantine == QuarantineS3 && config.QuarantineBucket == "" {
+		return config, fmt.Errorf("QUARANTINE_BUCKET is required to quarantine lines to s3")
+	}
+
+	if err := config.Anonymisation.Validate(); err != nil {
+		return config, err
+	}
+
+	usesHMAC := config.Anonymisation == redact.ModeHMAC
+
+	for _, mode := range config.AnonymisationLogGroups {
+		if err := mode.Validate(); err != nil {
+			return config, err
+		}
+		usesHMAC = usesHMAC || mode == redact.ModeHMAC
+	}
+
+	if usesHMAC && config.AnonymisationKey == "" {
+		return config, fmt.Errorf("IP_HMAC_KEY is required to anonymise client IPs with a hmac")
+	}
+
+	return config, nil
+}
+
+// splitList splits a comma separated list, ignoring whitespace and empty items.
+func splitList(list string) []string {
+	var items []string
+
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
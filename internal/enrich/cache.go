@@ -0,0 +1,61 @@
+package enrich
+
+import (
+	"container/list"
+	"sync"
+)
+
+// cache is a least recently used cache of lookups, safe for concurrent use.
+type cache[V any] struct {
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	lock    sync.Mutex
+}
+
+// cacheEntry is a value stored in the cache.
+type cacheEntry[V any] struct {
+	key   string
+	value V
+}
+
+// newCache creates a cache which holds up to size entries.
+func newCache[V any](size int) *cache[V] {
+	return &cache[V]{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Get a value from the cache, computing and storing it if it is missing.
+func (c *cache[V]) Get(key string, compute func(key string) V) V {
+	c.lock.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		c.lock.Unlock()
+		return element.Value.(*cacheEntry[V]).value
+	}
+	c.lock.Unlock()
+
+	value := compute(key)
+
+	if c.size < 1 {
+		return value
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.entries[key]; !ok {
+		c.entries[key] = c.order.PushFront(&cacheEntry[V]{key: key, value: value})
+	}
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry[V]).key)
+	}
+
+	return value
+}
@@ -0,0 +1,202 @@
+package enrich
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/oschwald/maxminddb-golang"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+const (
+	// PrefixClient is the prefix of the fields describing the client IP.
+	PrefixClient = "client"
+	// PrefixForwarded is the prefix of the fields describing the first x-forwarded-for hop.
+	PrefixForwarded = "forwarded"
+)
+
+const (
+	// FieldCountry is the ISO 3166-1 code of the country, eg. AU.
+	FieldCountry = "country"
+	// FieldRegion is the name of the largest subdivision of the country, eg. New South Wales.
+	FieldRegion = "region"
+	// FieldCity is the name of the city, eg. Sydney.
+	FieldCity = "city"
+	// FieldASN is the autonomous system number.
+	FieldASN = "asn"
+	// FieldOrganisation is the organisation of the autonomous system.
+	FieldOrganisation = "as_org"
+)
+
+// language of the names of regions and cities.
+const language = "en"
+
+// Location of an IP address.
+type Location struct {
+	// Country ISO 3166-1 code.
+	Country string
+	// Region name.
+	Region string
+	// City name.
+	City string
+	// ASN is the autonomous system number.
+	ASN uint
+	// Organisation of the autonomous system.
+	Organisation string
+}
+
+// GeoIP looks up the location of IP addresses in MaxMind databases.
+type GeoIP struct {
+	city *maxminddb.Reader
+	asn  *maxminddb.Reader
+	// failed is the amount of lookups which failed, eg. an IPv6 address in an IPv4 only database.
+	failed atomic.Int64
+}
+
+// cityRecord is the structure of a GeoIP2 or GeoLite2 City or Country database.
+type cityRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// asnRecord is the structure of a GeoLite2 ASN database.
+type asnRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organisation string `maxminddb:"autonomous_system_organization"`
+}
+
+// LoadGeoIP loads the City (or Country) and ASN databases at the paths, either of which may be empty.
+func LoadGeoIP(cityPath, asnPath string) (*GeoIP, error) {
+	var (
+		g   = &GeoIP{}
+		err error
+	)
+
+	if cityPath != "" {
+		g.city, err = openDatabase(cityPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if asnPath != "" {
+		g.asn, err = openDatabase(asnPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return g, nil
+}
+
+// openDatabase reads the whole database into memory, so it is released with the enricher.
+func openDatabase(path string) (*maxminddb.Reader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GeoIP database: %w", err)
+	}
+
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database %s: %w", path, err)
+	}
+
+	return reader, nil
+}
+
+// Enrich the record with the location of the client IP and the first x-forwarded-for hop.
+func (g *GeoIP) Enrich(record *parser.AccessLogRecord) error {
+	g.enrich(record, PrefixClient, record.ClientIP)
+	g.enrich(record, PrefixForwarded, FirstForwardedFor(record.ForwardedFor))
+
+	return nil
+}
+
+// enrich the record with the location of the IP, using the prefix for the field names. A lookup which fails is
+// counted rather than failing the object, and leaves the fields empty.
+func (g *GeoIP) enrich(record *parser.AccessLogRecord, prefix, address string) {
+	if address == "" {
+		return
+	}
+
+	location, err := g.Lookup(address)
+	if err != nil {
+		g.failed.Add(1)
+		return
+	}
+
+	setString(record, prefix+"_"+FieldCountry, location.Country)
+	setString(record, prefix+"_"+FieldRegion, location.Region)
+	setString(record, prefix+"_"+FieldCity, location.City)
+
+	if location.ASN > 0 {
+		record.SetValue(prefix+"_"+FieldASN, location.ASN)
+	}
+
+	setString(record, prefix+"_"+FieldOrganisation, location.Organisation)
+}
+
+// Failed returns the amount of lookups which have failed since the databases were loaded.
+func (g *GeoIP) Failed() int64 {
+	return g.failed.Load()
+}
+
+// Lookup the location of an IP address, returning an empty location if it isn't a valid address or isn't found.
+func (g *GeoIP) Lookup(address string) (Location, error) {
+	var location Location
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return location, nil
+	}
+
+	if g.city != nil {
+		var record cityRecord
+		if err := g.city.Lookup(ip, &record); err != nil {
+			return location, fmt.Errorf("failed to lookup location of %s: %w", address, err)
+		}
+
+		location.Country = record.Country.ISOCode
+		location.City = record.City.Names[language]
+
+		if len(record.Subdivisions) > 0 {
+			location.Region = record.Subdivisions[0].Names[language]
+		}
+	}
+
+	if g.asn != nil {
+		var record asnRecord
+		if err := g.asn.Lookup(ip, &record); err != nil {
+			return location, fmt.Errorf("failed to lookup ASN of %s: %w", address, err)
+		}
+
+		location.ASN = record.Number
+		location.Organisation = record.Organisation
+	}
+
+	return location, nil
+}
+
+// FirstForwardedFor returns the first hop of an x-forwarded-for value, which may be URL-encoded.
+func FirstForwardedFor(value string) string {
+	first, _, _ := strings.Cut(parser.Unescape(value), ",")
+	return strings.TrimSpace(first)
+}
+
+// setString sets the field if the value isn't empty.
+func setString(record *parser.AccessLogRecord, name, value string) {
+	if value != "" {
+		record.SetValue(name, value)
+	}
+}
@@ -0,0 +1,110 @@
+package enrich
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// The fixture databases contain:
+//   - 192.0.2.0/24: AU, NSW name, Sydney, AS64496 Example Sydney Networks
+//   - 198.51.100.0/24: DE, HE name, Frankfurt am Main, AS64497 Example Frankfurt Hosting
+//   - 2001:db8::/32: US, WA name, Seattle, no ASN
+//
+// The IPv4 only ASN database contains 192.0.2.0/24.
+const (
+	testCityDatabase = "testdata/GeoIP2-City-Test.mmdb"
+	testASNDatabase  = "testdata/GeoLite2-ASN-Test.mmdb"
+)
+
+func TestGeoIP_Lookup(t *testing.T) {
+	g, err := LoadGeoIP(testCityDatabase, testASNDatabase)
+	assert.NoError(t, err)
+
+	location, err := g.Lookup("192.0.2.10")
+	assert.NoError(t, err)
+	assert.Equal(t, Location{Country: "AU", Region: "NSW name", City: "Sydney", ASN: 64496, Organisation: "Example Sydney Networks"}, location)
+
+	location, err = g.Lookup("2001:db8::1")
+	assert.NoError(t, err)
+	assert.Equal(t, Location{Country: "US", Region: "WA name", City: "Seattle"}, location)
+
+	// Addresses which aren't in the database, or aren't addresses at all, have no location.
+	location, err = g.Lookup("203.0.113.1")
+	assert.NoError(t, err)
+	assert.Equal(t, Location{}, location)
+
+	location, err = g.Lookup("unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, Location{}, location)
+
+	// Only the ASN database.
+	g, err = LoadGeoIP("", testASNDatabase)
+	assert.NoError(t, err)
+
+	location, err = g.Lookup("198.51.100.7")
+	assert.NoError(t, err)
+	assert.Equal(t, Location{ASN: 64497, Organisation: "Example Frankfurt Hosting"}, location)
+
+	_, err = LoadGeoIP("testdata/missing.mmdb", "")
+	assert.Error(t, err)
+
+	_, err = LoadGeoIP("regexes.yaml", "")
+	assert.Error(t, err)
+}
+
+func TestGeoIP_Enrich(t *testing.T) {
+	g, err := LoadGeoIP(testCityDatabase, testASNDatabase)
+	assert.NoError(t, err)
+
+	record, err := parser.ParseRecord([]string{"date", "time", "c-ip", "x-forwarded-for"}, "2020-06-18	03:38:13	198.51.100.7	192.0.2.10,%20203.0.113.1")
+	assert.NoError(t, err)
+	assert.NoError(t, g.Enrich(record))
+
+	message, err := record.JSON()
+	assert.NoError(t, err)
+
+	var fields map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(message), &fields))
+	assert.Equal(t, "DE", fields["client_country"])
+	assert.Equal(t, "HE name", fields["client_region"])
+	assert.Equal(t, "Frankfurt am Main", fields["client_city"])
+	assert.Equal(t, float64(64497), fields["client_asn"])
+	assert.Equal(t, "Example Frankfurt Hosting", fields["client_as_org"])
+	assert.Equal(t, "AU", fields["forwarded_country"])
+	assert.Equal(t, "Sydney", fields["forwarded_city"])
+	assert.Equal(t, float64(64496), fields["forwarded_asn"])
+
+	// Fields are only added when the address is found.
+	record, err = parser.ParseRecord([]string{"date", "time", "c-ip", "x-forwarded-for"}, "2020-06-18	03:38:13	203.0.113.1	-")
+	assert.NoError(t, err)
+	assert.NoError(t, g.Enrich(record))
+	assert.Equal(t, []string{"date", "time", "c-ip", "x-forwarded-for"}, record.Fields())
+}
+
+func TestFirstForwardedFor(t *testing.T) {
+	assert.Equal(t, "192.0.2.10", FirstForwardedFor("192.0.2.10"))
+	assert.Equal(t, "192.0.2.10", FirstForwardedFor("192.0.2.10,%20203.0.113.1"))
+	assert.Equal(t, "192.0.2.10", FirstForwardedFor("192.0.2.10, 203.0.113.1"))
+	assert.Equal(t, "", FirstForwardedFor(""))
+}
+
+func TestGeoIP_EnrichFailed(t *testing.T) {
+	// The database only has IPv4 addresses, so looking up an IPv6 address fails.
+	g, err := LoadGeoIP("", "testdata/GeoLite2-ASN-IPv4-Test.mmdb")
+	assert.NoError(t, err)
+
+	_, err = g.Lookup("2001:db8::1")
+	assert.Error(t, err)
+
+	record, err := parser.ParseRecord([]string{"date", "time", "c-ip", "x-forwarded-for"}, "2020-06-18	03:38:13	2001:db8::1	192.0.2.10")
+	assert.NoError(t, err)
+	assert.NoError(t, g.Enrich(record))
+
+	// The client is left without a location, while the forwarded address is still enriched.
+	assert.Equal(t, []string{"date", "time", "c-ip", "x-forwarded-for", "forwarded_asn", "forwarded_as_org"}, record.Fields())
+	assert.Equal(t, int64(1), g.Failed())
+}
@@ -0,0 +1,118 @@
+package enrich
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+const (
+	// FieldPOP is the airport code of the edge location, eg. SYD.
+	FieldPOP = "pop"
+	// FieldPOPCity is the city of the edge location.
+	FieldPOPCity = "pop_city"
+	// FieldPOPCountry is the ISO 3166-1 code of the country of the edge location.
+	FieldPOPCountry = "pop_country"
+	// FieldPOPContinent is the continent of the edge location.
+	FieldPOPContinent = "pop_continent"
+)
+
+// defaultPOPs is the bundled table of edge locations.
+//
+//go:embed pops.yaml
+var defaultPOPs []byte
+
+// POP is the metadata of an edge location.
+type POP struct {
+	// Code is the airport code of the edge location.
+	Code string `yaml:"-"`
+	// City of the edge location.
+	City string `yaml:"city"`
+	// Country ISO 3166-1 code.
+	Country string `yaml:"country"`
+	// Continent of the edge location.
+	Continent string `yaml:"continent"`
+}
+
+// POPs resolves edge locations into their metadata.
+type POPs struct {
+	pops map[string]POP
+}
+
+// LoadPOPs loads the table at the path, or the bundled table if the path is empty.
+func LoadPOPs(path string) (*POPs, error) {
+	if path == "" {
+		return NewPOPs(defaultPOPs)
+	}
+
+	table, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read POP table: %w", err)
+	}
+
+	return NewPOPs(table)
+}
+
+// NewPOPs creates a resolver from a table of airport codes and their metadata.
+func NewPOPs(table []byte) (*POPs, error) {
+	pops := make(map[string]POP)
+
+	if err := yaml.Unmarshal(table, &pops); err != nil {
+		return nil, fmt.Errorf("failed to parse POP table: %w", err)
+	}
+
+	for code, pop := range pops {
+		pop.Code = code
+		pops[code] = pop
+	}
+
+	return &POPs{
+		pops: pops,
+	}, nil
+}
+
+// Lookup the metadata of an edge location, eg. SYD4-C2, returning false if the airport code isn't in the table.
+func (p *POPs) Lookup(edgeLocation string) (POP, bool) {
+	code := Code(edgeLocation)
+
+	pop, ok := p.pops[code]
+	if !ok {
+		return POP{Code: code}, false
+	}
+
+	return pop, true
+}
+
+// Enrich the record with the metadata of its edge location.
+func (p *POPs) Enrich(record *parser.AccessLogRecord) error {
+	if record.EdgeLocation == "" {
+		return nil
+	}
+
+	pop, _ := p.Lookup(record.EdgeLocation)
+
+	setString(record, FieldPOP, pop.Code)
+	setString(record, FieldPOPCity, pop.City)
+	setString(record, FieldPOPCountry, pop.Country)
+	setString(record, FieldPOPContinent, pop.Continent)
+
+	return nil
+}
+
+// Code returns the airport code at the start of an edge location, eg. SYD for SYD4-C2.
+func Code(edgeLocation string) string {
+	end := strings.IndexFunc(edgeLocation, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if end < 0 {
+		end = len(edgeLocation)
+	}
+
+	return strings.ToUpper(edgeLocation[:end])
+}
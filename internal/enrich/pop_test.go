@@ -0,0 +1,107 @@
+package enrich
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+func TestCode(t *testing.T) {
+	assert.Equal(t, "SYD", Code("SYD4-C2"))
+	assert.Equal(t, "FRA", Code("FRA2"))
+	assert.Equal(t, "LHR", Code("lhr61-p1"))
+	assert.Equal(t, "HIO", Code("HIO"))
+	assert.Equal(t, "", Code(""))
+}
+
+func TestPOPs_Lookup(t *testing.T) {
+	pops, err := LoadPOPs("")
+	assert.NoError(t, err)
+
+	pop, ok := pops.Lookup("SYD4-C2")
+	assert.True(t, ok)
+	assert.Equal(t, POP{Code: "SYD", City: "Sydney", Country: "AU", Continent: "Oceania"}, pop)
+
+	pop, ok = pops.Lookup("FRA2")
+	assert.True(t, ok)
+	assert.Equal(t, "DE", pop.Country)
+
+	// Unknown edge locations still have a code.
+	pop, ok = pops.Lookup("XYZ1-C1")
+	assert.False(t, ok)
+	assert.Equal(t, POP{Code: "XYZ"}, pop)
+
+	// The table can be replaced.
+	pops, err = NewPOPs([]byte("XYZ: {city: Somewhere, country: AQ, continent: Antarctica}"))
+	assert.NoError(t, err)
+
+	pop, ok = pops.Lookup("XYZ1-C1")
+	assert.True(t, ok)
+	assert.Equal(t, "Antarctica", pop.Continent)
+
+	_, err = NewPOPs([]byte("- nope"))
+	assert.Error(t, err)
+}
+
+func TestPOPs_Enrich(t *testing.T) {
+	pops, err := LoadPOPs("")
+	assert.NoError(t, err)
+
+	record, err := parser.ParseRecord([]string{"date", "time", "x-edge-location", "sc-status"}, "2020-06-18	03:38:13	SYD4-C2	200")
+	assert.NoError(t, err)
+	assert.NoError(t, pops.Enrich(record))
+
+	message, err := record.JSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"edge_location":"SYD4-C2","status":200,"pop":"SYD","pop_city":"Sydney","pop_country":"AU","pop_continent":"Oceania"}`, message)
+}
+
+func TestPOPSummary(t *testing.T) {
+	pops, err := LoadPOPs("")
+	assert.NoError(t, err)
+
+	summary := NewPOPSummary(pops)
+
+	fields := []string{"date", "time", "x-edge-location", "sc-bytes", "sc-status", "time-taken"}
+	for _, line := range []string{
+		"2020-06-18	03:38:13	SYD4-C2	100	200	0.100",
+		"2020-06-18	03:38:12	SYD1-C1	200	503	0.200",
+		"2020-06-18	03:38:15	SYD4-C2	300	404	0.300",
+		"2020-06-18	03:38:14	FRA2	400	301	-",
+		"2020-06-18	03:38:14	-	400	200	-",
+	} {
+		record, err := parser.ParseRecord(fields, line)
+		assert.NoError(t, err)
+		summary.Observe(record)
+	}
+
+	stats := summary.Stats()
+	assert.Len(t, stats, 2)
+
+	assert.Equal(t, "FRA", stats[0].POP)
+	assert.Equal(t, int64(1), stats[0].Status3xx)
+
+	assert.Equal(t, "SYD", stats[1].POP)
+	assert.Equal(t, "Oceania", stats[1].Continent)
+	assert.Equal(t, int64(3), stats[1].Requests)
+	assert.Equal(t, int64(1), stats[1].Status2xx)
+	assert.Equal(t, int64(1), stats[1].Status4xx)
+	assert.Equal(t, int64(1), stats[1].Status5xx)
+	assert.Equal(t, int64(600), stats[1].Bytes)
+	assert.InDelta(t, 0.6, stats[1].TimeTaken, 0.0001)
+	assert.Equal(t, time.Date(2020, 6, 18, 3, 38, 12, 0, time.UTC), stats[1].Start)
+	assert.Equal(t, time.Date(2020, 6, 18, 3, 38, 15, 0, time.UTC), stats[1].End)
+
+	message, err := stats[1].JSON()
+	assert.NoError(t, err)
+
+	var summaryFields map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(message), &summaryFields))
+	assert.Equal(t, SummaryType, summaryFields["type"])
+	assert.Equal(t, "Sydney", summaryFields["pop_city"])
+	assert.Equal(t, float64(1), summaryFields["status_5xx"])
+}
@@ -0,0 +1,144 @@
+package enrich
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// SummaryType identifies summary events amongst other events.
+const SummaryType = "pop_summary"
+
+// POPSummary counts the requests served by each edge location.
+type POPSummary struct {
+	pops  *POPs
+	stats map[string]*POPStats
+	lock  sync.Mutex
+}
+
+// POPStats summarise the requests served by an edge location.
+type POPStats struct {
+	// Type is always pop_summary.
+	Type string `json:"type"`
+	// POP is the airport code of the edge location.
+	POP string `json:"pop"`
+	// City of the edge location.
+	City string `json:"pop_city,omitempty"`
+	// Country of the edge location.
+	Country string `json:"pop_country,omitempty"`
+	// Continent of the edge location.
+	Continent string `json:"pop_continent,omitempty"`
+	// Requests served.
+	Requests int64 `json:"requests"`
+	// Status2xx is the amount of successful responses.
+	Status2xx int64 `json:"status_2xx"`
+	// Status3xx is the amount of redirects.
+	Status3xx int64 `json:"status_3xx"`
+	// Status4xx is the amount of client errors.
+	Status4xx int64 `json:"status_4xx"`
+	// Status5xx is the amount of server errors.
+	Status5xx int64 `json:"status_5xx"`
+	// Bytes sent to viewers.
+	Bytes int64 `json:"bytes"`
+	// TimeTaken is the total time taken in seconds to serve the requests.
+	TimeTaken float64 `json:"time_taken"`
+	// Start is the time of the first request.
+	Start time.Time `json:"start"`
+	// End is the time of the last request.
+	End time.Time `json:"end"`
+}
+
+// NewPOPSummary creates a summary which resolves edge locations using the table.
+func NewPOPSummary(pops *POPs) *POPSummary {
+	return &POPSummary{
+		pops:  pops,
+		stats: make(map[string]*POPStats),
+	}
+}
+
+// Observe counts the record towards the summary of its edge location, leaving it unchanged.
+func (s *POPSummary) Observe(record *parser.AccessLogRecord) {
+	if record.EdgeLocation == "" {
+		return
+	}
+
+	pop, _ := s.pops.Lookup(record.EdgeLocation)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats, ok := s.stats[pop.Code]
+	if !ok {
+		stats = &POPStats{
+			Type:      SummaryType,
+			POP:       pop.Code,
+			City:      pop.City,
+			Country:   pop.Country,
+			Continent: pop.Continent,
+			Start:     record.Timestamp,
+		}
+		s.stats[pop.Code] = stats
+	}
+
+	stats.Requests++
+
+	if record.Status != nil {
+		switch *record.Status / 100 {
+		case 2:
+			stats.Status2xx++
+		case 3:
+			stats.Status3xx++
+		case 4:
+			stats.Status4xx++
+		case 5:
+			stats.Status5xx++
+		}
+	}
+
+	if record.SCBytes != nil {
+		stats.Bytes += *record.SCBytes
+	}
+
+	if record.TimeTaken != nil {
+		stats.TimeTaken += *record.TimeTaken
+	}
+
+	if record.Timestamp.Before(stats.Start) {
+		stats.Start = record.Timestamp
+	}
+
+	if record.Timestamp.After(stats.End) {
+		stats.End = record.Timestamp
+	}
+}
+
+// Stats of each edge location, ordered by airport code.
+func (s *POPSummary) Stats() []POPStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats := make([]POPStats, 0, len(s.stats))
+
+	for _, pop := range s.stats {
+		stats = append(stats, *pop)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].POP < stats[j].POP
+	})
+
+	return stats
+}
+
+// JSON returns the stats as a JSON object.
+func (s POPStats) JSON() (string, error) {
+	message, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+
+	return string(message), nil
+}
@@ -0,0 +1,280 @@
+package enrich
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+const (
+	// FieldUserAgentFamily is the browser or client family, eg. Chrome.
+	FieldUserAgentFamily = "ua_family"
+	// FieldUserAgentMajor is the major version of the browser or client.
+	FieldUserAgentMajor = "ua_major"
+	// FieldUserAgentOS is the operating system family, eg. Mac OS X.
+	FieldUserAgentOS = "ua_os"
+	// FieldUserAgentDeviceType is the class of device: desktop, mobile, tablet, bot or other.
+	FieldUserAgentDeviceType = "ua_device_type"
+	// FieldUserAgentIsBot is true if the user agent is a bot, monitor or scripted client.
+	FieldUserAgentIsBot = "ua_is_bot"
+)
+
+const (
+	// DeviceTypeDesktop is a desktop or laptop computer.
+	DeviceTypeDesktop = "desktop"
+	// DeviceTypeMobile is a phone.
+	DeviceTypeMobile = "mobile"
+	// DeviceTypeTablet is a tablet.
+	DeviceTypeTablet = "tablet"
+	// DeviceTypeBot is a bot, monitor or scripted client.
+	DeviceTypeBot = "bot"
+	// DeviceTypeOther is anything else.
+	DeviceTypeOther = "other"
+)
+
+// familyOther is the family of anything which isn't matched.
+const familyOther = "Other"
+
+// deviceSpider is the device family used by uap-core for bots.
+const deviceSpider = "Spider"
+
+// defaultRegexes is the bundled user agent database.
+//
+//go:embed regexes.yaml
+var defaultRegexes []byte
+
+var (
+	// mobileOS are operating systems which are only used by phones and tablets.
+	mobileOS = map[string]bool{
+		"iOS":           true,
+		"Android":       true,
+		"Windows Phone": true,
+	}
+	// desktopOS are operating systems which are only used by computers.
+	desktopOS = map[string]bool{
+		"Windows":   true,
+		"Mac OS X":  true,
+		"Chrome OS": true,
+		"Linux":     true,
+		"Ubuntu":    true,
+		"Fedora":    true,
+		"Debian":    true,
+		"CentOS":    true,
+		"FreeBSD":   true,
+		"OpenBSD":   true,
+		"NetBSD":    true,
+	}
+	// tabletDevice matches device families which are tablets.
+	tabletDevice = regexp.MustCompile(`(?i)(ipad|tablet|kindle|samsung sm-[tpx])`)
+)
+
+// UserAgent is a parsed user agent.
+type UserAgent struct {
+	// Family of the browser or client, eg. Chrome.
+	Family string
+	// Major version of the browser or client.
+	Major string
+	// OS family, eg. Mac OS X.
+	OS string
+	// OSMajor version.
+	OSMajor string
+	// Device family, eg. iPhone.
+	Device string
+	// DeviceType is the class of device.
+	DeviceType string
+	// Bot is true if the user agent is a bot, monitor or scripted client.
+	Bot bool
+}
+
+// UserAgentParser parses user agents using a uap-core regexes.yaml database.
+type UserAgentParser struct {
+	agents  []uaRegex
+	oses    []uaRegex
+	devices []uaRegex
+	cache   *cache[UserAgent]
+}
+
+// uaDatabase is the structure of a uap-core regexes.yaml file.
+type uaDatabase struct {
+	UserAgentParsers []uaDefinition `yaml:"user_agent_parsers"`
+	OSParsers        []uaDefinition `yaml:"os_parsers"`
+	DeviceParsers    []uaDefinition `yaml:"device_parsers"`
+}
+
+// uaDefinition is a single parser in a uap-core regexes.yaml file.
+type uaDefinition struct {
+	Regex             string `yaml:"regex"`
+	RegexFlag         string `yaml:"regex_flag"`
+	FamilyReplacement string `yaml:"family_replacement"`
+	V1Replacement     string `yaml:"v1_replacement"`
+	OSReplacement     string `yaml:"os_replacement"`
+	OSV1Replacement   string `yaml:"os_v1_replacement"`
+	DeviceReplacement string `yaml:"device_replacement"`
+}
+
+// uaRegex is a compiled parser.
+type uaRegex struct {
+	regex   *regexp.Regexp
+	family  string
+	version string
+}
+
+// LoadUserAgentParser loads the database at the path, or the bundled database if the path is empty.
+func LoadUserAgentParser(path string, cacheSize int) (*UserAgentParser, error) {
+	if path == "" {
+		return NewUserAgentParser(defaultRegexes, cacheSize)
+	}
+
+	regexes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user agent database: %w", err)
+	}
+
+	return NewUserAgentParser(regexes, cacheSize)
+}
+
+// NewUserAgentParser creates a parser from a uap-core regexes.yaml database, caching up to cacheSize user agents.
+func NewUserAgentParser(regexes []byte, cacheSize int) (*UserAgentParser, error) {
+	var database uaDatabase
+
+	if err := yaml.Unmarshal(regexes, &database); err != nil {
+		return nil, fmt.Errorf("failed to parse user agent database: %w", err)
+	}
+
+	p := &UserAgentParser{
+		cache: newCache[UserAgent](cacheSize),
+	}
+
+	for _, definition := range database.UserAgentParsers {
+		p.agents = appendRegex(p.agents, definition, definition.FamilyReplacement, definition.V1Replacement)
+	}
+
+	for _, definition := range database.OSParsers {
+		p.oses = appendRegex(p.oses, definition, definition.OSReplacement, definition.OSV1Replacement)
+	}
+
+	for _, definition := range database.DeviceParsers {
+		p.devices = appendRegex(p.devices, definition, definition.DeviceReplacement, "")
+	}
+
+	return p, nil
+}
+
+// appendRegex compiles the definition, skipping regexes which use features not supported by Go.
+func appendRegex(regexes []uaRegex, definition uaDefinition, family, version string) []uaRegex {
+	expr := definition.Regex
+	if definition.RegexFlag == "i" {
+		expr = "(?i)" + expr
+	}
+
+	regex, err := regexp.Compile(expr)
+	if err != nil {
+		return regexes
+	}
+
+	return append(regexes, uaRegex{
+		regex:   regex,
+		family:  family,
+		version: version,
+	})
+}
+
+// Enrich the record with fields describing the user agent.
+func (p *UserAgentParser) Enrich(record *parser.AccessLogRecord) error {
+	if record.UserAgent == "" {
+		return nil
+	}
+
+	ua := p.Parse(record.UserAgent)
+
+	record.SetValue(FieldUserAgentFamily, ua.Family)
+	record.SetValue(FieldUserAgentMajor, ua.Major)
+	record.SetValue(FieldUserAgentOS, ua.OS)
+	record.SetValue(FieldUserAgentDeviceType, ua.DeviceType)
+	record.SetValue(FieldUserAgentIsBot, ua.Bot)
+
+	return nil
+}
+
+// Parse a user agent, which may be URL-encoded.
+func (p *UserAgentParser) Parse(userAgent string) UserAgent {
+	return p.cache.Get(userAgent, func(userAgent string) UserAgent {
+		return p.parse(parser.Unescape(userAgent))
+	})
+}
+
+// parse a decoded user agent.
+func (p *UserAgentParser) parse(userAgent string) UserAgent {
+	ua := UserAgent{
+		Family: familyOther,
+		OS:     familyOther,
+		Device: familyOther,
+	}
+
+	ua.Family, ua.Major = match(p.agents, userAgent)
+	ua.OS, ua.OSMajor = match(p.oses, userAgent)
+	ua.Device, _ = match(p.devices, userAgent)
+
+	ua.Bot = ua.Device == deviceSpider
+	ua.DeviceType = deviceType(ua)
+
+	return ua
+}
+
+// deviceType classifies the device of a parsed user agent.
+func deviceType(ua UserAgent) string {
+	switch {
+	case ua.Bot:
+		return DeviceTypeBot
+	case tabletDevice.MatchString(ua.Device):
+		return DeviceTypeTablet
+	case mobileOS[ua.OS]:
+		return DeviceTypeMobile
+	case desktopOS[ua.OS]:
+		return DeviceTypeDesktop
+	default:
+		return DeviceTypeOther
+	}
+}
+
+// match the user agent against the regexes, returning the family and version of the first match.
+func match(regexes []uaRegex, userAgent string) (string, string) {
+	for _, r := range regexes {
+		groups := r.regex.FindStringSubmatch(userAgent)
+		if groups == nil {
+			continue
+		}
+
+		family := replace(r.family, groups, 1)
+		if family == "" {
+			family = familyOther
+		}
+
+		return family, replace(r.version, groups, 2)
+	}
+
+	return familyOther, ""
+}
+
+// replace the $1-$9 placeholders of the replacement with the groups, or use the group at the index if there is no replacement.
+func replace(replacement string, groups []string, index int) string {
+	if replacement == "" {
+		if index < len(groups) {
+			return strings.TrimSpace(groups[index])
+		}
+		return ""
+	}
+
+	for i := len(groups) - 1; i > 0 && i < 10; i-- {
+		replacement = strings.ReplaceAll(replacement, "$"+strconv.Itoa(i), groups[i])
+	}
+
+	return strings.TrimSpace(replacement)
+}
@@ -0,0 +1,131 @@
+package enrich
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+func TestUserAgentParser_Parse(t *testing.T) {
+	p, err := LoadUserAgentParser("", 10)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		userAgent string
+		expected  UserAgent
+	}{
+		{
+			userAgent: "Mozilla/5.0%20(Macintosh;%20Intel%20Mac%20OS%20X%2010_14_5)%20AppleWebKit/537.36%20(KHTML,%20like%20Gecko)%20Chrome/83.0.4103.97%20Safari/537.36",
+			expected:  UserAgent{Family: "Chrome", Major: "83", OS: "Mac OS X", OSMajor: "10", Device: "Mac", DeviceType: DeviceTypeDesktop},
+		},
+		{
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1",
+			expected:  UserAgent{Family: "Mobile Safari", Major: "17", OS: "iOS", OSMajor: "17", Device: "iPhone", DeviceType: DeviceTypeMobile},
+		},
+		{
+			userAgent: "Mozilla/5.0 (Linux; Android 14; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+			expected:  UserAgent{Family: "Chrome", Major: "126", OS: "Android", OSMajor: "14", Device: "Samsung SM-X710", DeviceType: DeviceTypeTablet},
+		},
+		{
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.0.0",
+			expected:  UserAgent{Family: "Edge", Major: "126", OS: "Windows", OSMajor: "10", Device: "Other", DeviceType: DeviceTypeDesktop},
+		},
+		{
+			userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			expected:  UserAgent{Family: "Googlebot", Major: "2", OS: "Other", Device: "Spider", DeviceType: DeviceTypeBot, Bot: true},
+		},
+		{
+			userAgent: "Pingdom.com_bot_version_1.4_(http://www.pingdom.com/)",
+			expected:  UserAgent{Family: "PingdomBot", Major: "1", OS: "Other", Device: "Spider", DeviceType: DeviceTypeBot, Bot: true},
+		},
+		{
+			userAgent: "curl/8.4.0",
+			expected:  UserAgent{Family: "curl", Major: "8", OS: "Other", Device: "Spider", DeviceType: DeviceTypeBot, Bot: true},
+		},
+		{
+			userAgent: "something unknown",
+			expected:  UserAgent{Family: "Other", OS: "Other", Device: "Other", DeviceType: DeviceTypeOther},
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, p.Parse(test.userAgent), test.userAgent)
+	}
+
+	// Only the most recently used agents are kept.
+	assert.Equal(t, 8, p.cache.order.Len())
+}
+
+func TestUserAgentParser_Cache(t *testing.T) {
+	p, err := NewUserAgentParser(defaultRegexes, 2)
+	assert.NoError(t, err)
+
+	p.Parse("curl/8.4.0")
+	p.Parse("Wget/1.21")
+	p.Parse("curl/8.4.0")
+	p.Parse("python-requests/2.31.0")
+
+	assert.Equal(t, 2, p.cache.order.Len())
+	assert.Contains(t, p.cache.entries, "curl/8.4.0")
+	assert.Contains(t, p.cache.entries, "python-requests/2.31.0")
+	assert.NotContains(t, p.cache.entries, "Wget/1.21")
+}
+
+func TestNewUserAgentParser_Replacements(t *testing.T) {
+	regexes := []byte(`
+user_agent_parsers:
+  - regex: '(Custom)Agent/(\d+)\.(\d+)'
+    family_replacement: '$1 Browser'
+    v1_replacement: '$2$3'
+  - regex: '(?<=lookbehind)'
+os_parsers:
+  - regex: 'customos'
+    regex_flag: 'i'
+    os_replacement: 'Custom OS'
+`)
+
+	p, err := NewUserAgentParser(regexes, 0)
+	assert.NoError(t, err)
+
+	// Regexes which aren't supported by Go are skipped.
+	assert.Len(t, p.agents, 1)
+
+	ua := p.Parse("CustomAgent/1.2 (CUSTOMOS)")
+	assert.Equal(t, "Custom Browser", ua.Family)
+	assert.Equal(t, "12", ua.Major)
+	assert.Equal(t, "Custom OS", ua.OS)
+
+	_, err = NewUserAgentParser([]byte("user_agent_parsers: {"), 0)
+	assert.Error(t, err)
+}
+
+func TestUserAgentParser_Enrich(t *testing.T) {
+	p, err := LoadUserAgentParser("", 10)
+	assert.NoError(t, err)
+
+	record, err := parser.ParseRecord([]string{"date", "time", "sc-status", "cs(User-Agent)"}, "2020-06-18	03:38:13	200	curl/8.4.0")
+	assert.NoError(t, err)
+	assert.NoError(t, p.Enrich(record))
+
+	assert.Equal(t, "200	curl/8.4.0	curl	8	Other	bot	true", record.Message())
+
+	message, err := record.JSON()
+	assert.NoError(t, err)
+
+	var fields map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(message), &fields))
+	assert.Equal(t, "curl", fields[FieldUserAgentFamily])
+	assert.Equal(t, "8", fields[FieldUserAgentMajor])
+	assert.Equal(t, "Other", fields[FieldUserAgentOS])
+	assert.Equal(t, DeviceTypeBot, fields[FieldUserAgentDeviceType])
+	assert.Equal(t, true, fields[FieldUserAgentIsBot])
+
+	// Records without a user agent aren't enriched.
+	record, err = parser.ParseRecord([]string{"date", "time", "cs(User-Agent)"}, "2020-06-18	03:38:13	-")
+	assert.NoError(t, err)
+	assert.NoError(t, p.Enrich(record))
+	assert.NotContains(t, record.Fields(), FieldUserAgentFamily)
+}
@@ -0,0 +1,460 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// Expression is evaluated against a record.
+type Expression interface {
+	// Match returns true if the record matches the expression.
+	Match(record *parser.AccessLogRecord) bool
+}
+
+// Operators which compare a field to a value.
+const (
+	OperatorEqual        = "=="
+	OperatorNotEqual     = "!="
+	OperatorGreater      = ">"
+	OperatorGreaterEqual = ">="
+	OperatorLess         = "<"
+	OperatorLessEqual    = "<="
+	OperatorMatch        = "=~"
+	OperatorNotMatch     = "!~"
+	OperatorContains     = "contains"
+)
+
+// Compile an expression, eg. `status >= 500 and not uri_stem =~ "^/healthz"`.
+//
+// Fields are named by their JSON key or as they appear in the header, values are numbers, quoted strings or booleans.
+// Comparisons can be combined with and, or, not and parentheses.
+func Compile(expr string) (Expression, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &expressionParser{tokens: tokens}
+
+	expression, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q in expression", p.peek().text)
+	}
+
+	return expression, nil
+}
+
+// tokenKind is the kind of a token.
+type tokenKind int
+
+const (
+	tokenIdentifier tokenKind = iota
+	tokenOperator
+	tokenString
+	tokenNumber
+	tokenOpen
+	tokenClose
+)
+
+// token of an expression.
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits the expression into tokens.
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(expr); {
+		c := expr[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenOpen, text: "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenClose, text: ")"})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(expr) && expr[end] != '"' {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("unterminated string in expression")
+			}
+			value, err := strconv.Unquote(expr[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s in expression: %w", expr[i:end+1], err)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: value})
+			i = end + 1
+		case strings.ContainsRune("=!<>&|", rune(c)):
+			end := i + 1
+			for end < len(expr) && strings.ContainsRune("=~<>&|", rune(expr[end])) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: expr[i:end]})
+			i = end
+		case c == '-' || c == '.' || unicode.IsDigit(rune(c)):
+			end := i + 1
+			for end < len(expr) && (expr[end] == '.' || unicode.IsDigit(rune(expr[end]))) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: expr[i:end]})
+			i = end
+		case isIdentifier(rune(c)):
+			end := i + 1
+			for end < len(expr) && (isIdentifier(rune(expr[end])) || expr[end] == '-') {
+				end++
+			}
+			end = headerFieldEnd(expr, i, end)
+			tokens = append(tokens, token{kind: tokenIdentifier, text: expr[i:end]})
+			i = end
+		default:
+			return nil, fmt.Errorf("unexpected %q in expression", c)
+		}
+	}
+
+	return tokens, nil
+}
+
+// headerFieldEnd returns the end of a header field named as it appears in the header, eg. cs(User-Agent), where the
+// parenthesis directly follows cs or sc. Otherwise the end of the identifier is returned, so not(...) still groups.
+func headerFieldEnd(expr string, start, end int) int {
+	if prefix := expr[start:end]; prefix != "cs" && prefix != "sc" {
+		return end
+	}
+
+	if end >= len(expr) || expr[end] != '(' {
+		return end
+	}
+
+	for i := end + 1; i < len(expr); i++ {
+		switch {
+		case expr[i] == ')' && i > end+1:
+			return i + 1
+		case !isIdentifier(rune(expr[i])) && expr[i] != '-':
+			return end
+		}
+	}
+
+	return end
+}
+
+// isIdentifier returns true if the character can start a field name or keyword.
+func isIdentifier(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// expressionParser is a recursive descent parser of tokens.
+type expressionParser struct {
+	tokens []token
+	pos    int
+}
+
+// done returns true if all tokens have been parsed.
+func (p *expressionParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+// peek at the next token.
+func (p *expressionParser) peek() token {
+	if p.done() {
+		return token{}
+	}
+	return p.tokens[p.pos]
+}
+
+// next consumes the next token.
+func (p *expressionParser) next() (token, error) {
+	if p.done() {
+		return token{}, fmt.Errorf("unexpected end of expression")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, nil
+}
+
+// accept consumes the next token if it is one of the keywords or operators.
+func (p *expressionParser) accept(words ...string) bool {
+	t := p.peek()
+	if p.done() || (t.kind != tokenIdentifier && t.kind != tokenOperator) {
+		return false
+	}
+
+	for _, word := range words {
+		if strings.EqualFold(t.text, word) {
+			p.pos++
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseOr parses expressions joined by or.
+func (p *expressionParser) parseOr() (Expression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("or", "||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = or{left, right}
+	}
+
+	return left, nil
+}
+
+// parseAnd parses expressions joined by and.
+func (p *expressionParser) parseAnd() (Expression, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("and", "&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = and{left, right}
+	}
+
+	return left, nil
+}
+
+// parseNot parses a negated expression.
+func (p *expressionParser) parseNot() (Expression, error) {
+	if p.accept("not", "!") {
+		expression, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return not{expression}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+// parsePrimary parses a comparison or an expression in parentheses.
+func (p *expressionParser) parsePrimary() (Expression, error) {
+	if p.peek().kind == tokenOpen && !p.done() {
+		p.pos++
+
+		expression, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := p.next()
+		if err != nil || t.kind != tokenClose {
+			return nil, fmt.Errorf("missing closing parenthesis in expression")
+		}
+
+		return expression, nil
+	}
+
+	return p.parseComparison()
+}
+
+// parseComparison parses a field, an operator and a value.
+func (p *expressionParser) parseComparison() (Expression, error) {
+	field, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if field.kind != tokenIdentifier {
+		return nil, fmt.Errorf("expected a field but got %q", field.text)
+	}
+
+	operator, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	return newComparison(field.text, strings.ToLower(operator.text), value)
+}
+
+// or matches if either expression matches.
+type or [2]Expression
+
+// Match implements the interface.
+func (e or) Match(record *parser.AccessLogRecord) bool {
+	return e[0].Match(record) || e[1].Match(record)
+}
+
+// and matches if both expressions match.
+type and [2]Expression
+
+// Match implements the interface.
+func (e and) Match(record *parser.AccessLogRecord) bool {
+	return e[0].Match(record) && e[1].Match(record)
+}
+
+// not matches if the expression doesn't match.
+type not [1]Expression
+
+// Match implements the interface.
+func (e not) Match(record *parser.AccessLogRecord) bool {
+	return !e[0].Match(record)
+}
+
+// comparison of a field to a value.
+type comparison struct {
+	field    string
+	operator string
+	text     string
+	number   *float64
+	regex    *regexp.Regexp
+}
+
+// newComparison validates the operator and value of a comparison.
+func newComparison(field, operator string, value token) (*comparison, error) {
+	c := &comparison{
+		field:    field,
+		operator: operator,
+		text:     value.text,
+	}
+
+	switch value.kind {
+	case tokenNumber:
+		number, err := strconv.ParseFloat(value.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s in expression", value.text)
+		}
+		c.number = &number
+	case tokenString:
+	case tokenIdentifier:
+		// Booleans are compared by their text.
+		if value.text != "true" && value.text != "false" {
+			return nil, fmt.Errorf("expected a value but got %q, strings must be quoted", value.text)
+		}
+	default:
+		return nil, fmt.Errorf("expected a value but got %q", value.text)
+	}
+
+	switch operator {
+	case OperatorEqual, OperatorNotEqual, OperatorContains:
+	case OperatorGreater, OperatorGreaterEqual, OperatorLess, OperatorLessEqual:
+		if c.number == nil {
+			return nil, fmt.Errorf("%s %s requires a number", field, operator)
+		}
+	case OperatorMatch, OperatorNotMatch:
+		regex, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s in expression: %w", value.text, err)
+		}
+		c.regex = regex
+	default:
+		return nil, fmt.Errorf("unsupported operator %s in expression", operator)
+	}
+
+	return c, nil
+}
+
+// Match implements the interface.
+func (c *comparison) Match(record *parser.AccessLogRecord) bool {
+	name, ok := resolve(record, c.field)
+	if !ok {
+		// Missing fields only match negative comparisons.
+		return c.operator == OperatorNotEqual || c.operator == OperatorNotMatch
+	}
+
+	text := record.Get(name)
+	if text == parser.Empty {
+		text = ""
+	}
+
+	switch c.operator {
+	case OperatorContains:
+		return strings.Contains(text, c.text)
+	case OperatorMatch:
+		return c.regex.MatchString(text)
+	case OperatorNotMatch:
+		return !c.regex.MatchString(text)
+	}
+
+	if c.number == nil {
+		equal := text == c.text
+		if c.operator == OperatorNotEqual {
+			return !equal
+		}
+		return equal
+	}
+
+	number, ok := toNumber(record.Value(name))
+	if !ok {
+		return c.operator == OperatorNotEqual
+	}
+
+	switch c.operator {
+	case OperatorEqual:
+		return number == *c.number
+	case OperatorNotEqual:
+		return number != *c.number
+	case OperatorGreater:
+		return number > *c.number
+	case OperatorGreaterEqual:
+		return number >= *c.number
+	case OperatorLess:
+		return number < *c.number
+	default:
+		return number <= *c.number
+	}
+}
+
+// resolve the name of the field in the record, which may be named by its JSON key.
+func resolve(record *parser.AccessLogRecord, field string) (string, bool) {
+	for _, name := range record.Fields() {
+		if name == field || parser.Key(name) == field {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// toNumber converts a typed value to a number.
+func toNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case uint:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case string:
+		number, err := strconv.ParseFloat(v, 64)
+		return number, err == nil
+	}
+
+	return 0, false
+}
@@ -0,0 +1,124 @@
+package filter
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// Action taken when a rule matches.
+type Action string
+
+const (
+	// ActionDrop stops the event from being pushed.
+	ActionDrop Action = "drop"
+	// ActionKeep pushes the event.
+	ActionKeep Action = "keep"
+)
+
+// Rule decides whether events are pushed.
+type Rule struct {
+	// Name of the rule, used when counting matches.
+	Name string `yaml:"name"`
+	// Action taken when the rule matches.
+	Action Action `yaml:"action"`
+	// When the rule matches, eg. `status >= 500`.
+	When string `yaml:"when"`
+
+	// expression is the compiled condition.
+	expression Expression
+}
+
+// Rules are evaluated in order, the first rule which matches decides whether an event is pushed.
+type Rules struct {
+	rules []Rule
+}
+
+// Load the rules from a YAML file.
+func Load(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read filter rules: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// Parse the rules from YAML.
+func Parse(data []byte) (*Rules, error) {
+	var rules []Rule
+
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse filter rules: %w", err)
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+
+		if rule.Action != ActionDrop && rule.Action != ActionKeep {
+			return nil, fmt.Errorf("filter rule %s has an unsupported action: %s", rule.Name, rule.Action)
+		}
+
+		expression, err := Compile(rule.When)
+		if err != nil {
+			return nil, fmt.Errorf("filter rule %s is invalid: %w", rule.Name, err)
+		}
+		rule.expression = expression
+	}
+
+	return &Rules{
+		rules: rules,
+	}, nil
+}
+
+// New creates a filter which counts the events matched by each rule.
+func (r *Rules) New() *Filter {
+	return &Filter{
+		rules:  r.rules,
+		counts: make(map[string]int),
+	}
+}
+
+// Filter decides whether events are pushed, counting the events matched by each rule.
+type Filter struct {
+	rules   []Rule
+	counts  map[string]int
+	dropped int
+}
+
+// Keep returns true if the record should be pushed. Records which don't match any rule are kept.
+func (f *Filter) Keep(record *parser.AccessLogRecord) (bool, error) {
+	for _, rule := range f.rules {
+		if !rule.expression.Match(record) {
+			continue
+		}
+
+		f.counts[rule.Name]++
+
+		if rule.Action == ActionDrop {
+			f.dropped++
+			return false, nil
+		}
+
+		return true, nil
+	}
+
+	return true, nil
+}
+
+// Counts of the events matched by each rule.
+func (f *Filter) Counts() map[string]int {
+	return f.counts
+}
+
+// Dropped is the amount of events which were dropped.
+func (f *Filter) Dropped() int {
+	return f.dropped
+}
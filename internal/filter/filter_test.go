@@ -0,0 +1,135 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// testFields are the fields of the records used by the tests.
+var testFields = []string{"date", "time", "x-edge-location", "sc-status", "cs-uri-stem", "cs(User-Agent)", "time-taken"}
+
+// newTestRecord parses a record with the test fields.
+func newTestRecord(t *testing.T, line string) *parser.AccessLogRecord {
+	record, err := parser.ParseRecord(testFields, "2020-06-18	03:38:13	"+line)
+	assert.NoError(t, err)
+	return record
+}
+
+func TestCompile(t *testing.T) {
+	record := newTestRecord(t, "SYD4-C2	503	/healthz/ready	Pingdom.com_bot_version_1.4_(http://www.pingdom.com/)	0.250")
+
+	tests := []struct {
+		expr  string
+		match bool
+	}{
+		{expr: `status >= 500`, match: true},
+		{expr: `status > 503`, match: false},
+		{expr: `sc-status == 503`, match: true},
+		{expr: `status != 503`, match: false},
+		{expr: `status < 500 or time_taken <= 0.25`, match: true},
+		{expr: `uri_stem =~ "^/healthz"`, match: true},
+		{expr: `uri_stem !~ "^/healthz"`, match: false},
+		{expr: `user_agent contains "Pingdom"`, match: true},
+		{expr: `edge_location == "SYD4-C2" and not (status == 200 || status == 404)`, match: true},
+		{expr: `!(edge_location == "SYD4-C2")`, match: false},
+		{expr: `missing == "value"`, match: false},
+		{expr: `missing != "value"`, match: true},
+		{expr: `edge_location == "FRA2" OR status >= 500 AND uri_stem contains "ready"`, match: true},
+	}
+
+	for _, test := range tests {
+		expression, err := Compile(test.expr)
+		assert.NoError(t, err, test.expr)
+		assert.Equal(t, test.match, expression.Match(record), test.expr)
+	}
+
+	// Header fields with parentheses can be named as they appear in the header.
+	record, err := parser.ParseRecord(
+		[]string{"date", "time", "cs(Host)", "cs(Referer)", "cs(User-Agent)"},
+		"2020-06-18	03:38:13	d111111abcdef8.cloudfront.net	https://example.com/	Pingdom.com_bot_version_1.4",
+	)
+	assert.NoError(t, err)
+
+	for expr, match := range map[string]bool{
+		`cs(Host) == "d111111abcdef8.cloudfront.net"`:                 true,
+		`cs(Referer) =~ "^https://example\\.com/" and cs(Host) != ""`: true,
+		`not (cs(User-Agent) contains "Pingdom")`:                     false,
+		`not(cs(User-Agent) contains "curl")`:                         true,
+	} {
+		expression, err := Compile(expr)
+		assert.NoError(t, err, expr)
+		assert.Equal(t, match, expression.Match(record), expr)
+	}
+
+	// Empty values don't match comparisons with numbers.
+	record = newTestRecord(t, "SYD4-C2	-	/	-	-")
+	expression, err := Compile(`status < 500`)
+	assert.NoError(t, err)
+	assert.False(t, expression.Match(record))
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for expr, message := range map[string]string{
+		``:                           "unexpected end of expression",
+		`status >=`:                  "unexpected end of expression",
+		`status >= "500"`:            "requires a number",
+		`uri_stem == /healthz`:       "unexpected '/'",
+		`uri_stem == healthz`:        "strings must be quoted",
+		`uri_stem =~ "("`:            "invalid pattern",
+		`uri_stem ~= "x"`:            "unexpected '~'",
+		`uri_stem <> "x"`:            "unsupported operator",
+		`(status == 500`:             "missing closing parenthesis",
+		`status == 500 status`:       `unexpected "status"`,
+		`user_agent contains "Ping`:  "unterminated string",
+		`500 == status`:              "expected a field",
+		`status == 500 and and`:      "unexpected end of expression",
+		`status == 1.2.3`:            "invalid number",
+		`status == 500 or (`:         "unexpected end of expression",
+		`status == 500 and ( ) == 1`: "expected a field",
+	} {
+		_, err := Compile(expr)
+		assert.ErrorContains(t, err, message, expr)
+	}
+}
+
+func TestRules(t *testing.T) {
+	rules, err := Parse([]byte(`
+- name: errors
+  action: keep
+  when: status >= 500
+- name: health-checks
+  action: drop
+  when: uri_stem =~ "^/healthz"
+- action: drop
+  when: user_agent contains "Pingdom"
+`))
+	assert.NoError(t, err)
+
+	filter := rules.New()
+
+	for line, expected := range map[string]bool{
+		"SYD4-C2	503	/healthz	curl/8.4.0	0.001":           true,
+		"SYD4-C2	200	/healthz	curl/8.4.0	0.001":           false,
+		"SYD4-C2	200	/	Pingdom.com_bot_version_1.4	0.001": false,
+		"SYD4-C2	200	/	curl/8.4.0	0.001":                  true,
+	} {
+		keep, err := filter.Keep(newTestRecord(t, line))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, keep, line)
+	}
+
+	assert.Equal(t, map[string]int{"errors": 1, "health-checks": 1, "rule-3": 1}, filter.Counts())
+	assert.Equal(t, 2, filter.Dropped())
+
+	_, err = Parse([]byte(`[{action: ignore, when: "status == 200"}]`))
+	assert.ErrorContains(t, err, "unsupported action: ignore")
+
+	_, err = Parse([]byte(`[{name: broken, action: drop, when: "status =="}]`))
+	assert.ErrorContains(t, err, "filter rule broken is invalid")
+
+	_, err = Load("testdata/missing.yaml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,141 @@
+package filter
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// FieldSampleRate is the fraction of similar events which were kept, eg. 0.1 if 1 in 10 were kept.
+const FieldSampleRate = "sample_rate"
+
+// SampleRule decides the fraction of events which are kept.
+type SampleRule struct {
+	// Name of the rule, used when counting events.
+	Name string `yaml:"name"`
+	// When the rule matches, eg. `status < 400`, or always if empty.
+	When string `yaml:"when"`
+	// Rate is the fraction of the events which are kept, between 0 and 1.
+	Rate float64 `yaml:"rate"`
+
+	// expression is the compiled condition.
+	expression Expression
+}
+
+// SampleRules are evaluated in order, the first rule which matches decides the rate. Events which don't match any rule
+// are kept.
+type SampleRules struct {
+	rules []SampleRule
+}
+
+// LoadSampleRules loads the rules from a YAML file.
+func LoadSampleRules(path string) (*SampleRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sampling rules: %w", err)
+	}
+
+	return ParseSampleRules(data)
+}
+
+// ParseSampleRules parses the rules from YAML.
+func ParseSampleRules(data []byte) (*SampleRules, error) {
+	var rules []SampleRule
+
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse sampling rules: %w", err)
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+
+		if rule.Rate < 0 || rule.Rate > 1 {
+			return nil, fmt.Errorf("sampling rule %s has a rate outside of 0 to 1: %v", rule.Name, rule.Rate)
+		}
+
+		if rule.When == "" {
+			continue
+		}
+
+		expression, err := Compile(rule.When)
+		if err != nil {
+			return nil, fmt.Errorf("sampling rule %s is invalid: %w", rule.Name, err)
+		}
+		rule.expression = expression
+	}
+
+	return &SampleRules{
+		rules: rules,
+	}, nil
+}
+
+// New creates a sampler which counts the events sampled out by each rule.
+func (r *SampleRules) New() *Sampler {
+	return &Sampler{
+		rules:  r.rules,
+		counts: make(map[string]int),
+	}
+}
+
+// Sampler keeps a deterministic fraction of events, so a request is sampled the same way when it is processed again.
+type Sampler struct {
+	rules   []SampleRule
+	counts  map[string]int
+	dropped int
+}
+
+// Keep returns true if the record is sampled, adding the rate it was sampled at.
+func (s *Sampler) Keep(record *parser.AccessLogRecord) (bool, error) {
+	rate := 1.0
+	name := ""
+
+	for _, rule := range s.rules {
+		if rule.expression == nil || rule.expression.Match(record) {
+			rate, name = rule.Rate, rule.Name
+			break
+		}
+	}
+
+	if rate < 1 && position(record) >= rate {
+		s.counts[name]++
+		s.dropped++
+		return false, nil
+	}
+
+	record.SetValue(FieldSampleRate, rate)
+
+	return true, nil
+}
+
+// Counts of the events sampled out by each rule.
+func (s *Sampler) Counts() map[string]int {
+	return s.counts
+}
+
+// Dropped is the amount of events which were sampled out.
+func (s *Sampler) Dropped() int {
+	return s.dropped
+}
+
+// position of the record between 0 and 1, derived from its request id so it is the same each time it is processed.
+func position(record *parser.AccessLogRecord) float64 {
+	key := record.EdgeRequestID
+	if key == "" {
+		// Fall back to the whole line, which is just as deterministic.
+		key = record.Message()
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+
+	return float64(hash.Sum64()) / math.MaxUint64
+}
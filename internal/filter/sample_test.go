@@ -0,0 +1,100 @@
+package filter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// sampleFields are the fields of the records used by the sampling tests.
+var sampleFields = []string{"date", "time", "sc-status", "x-edge-result-type", "x-edge-request-id"}
+
+func TestSampler(t *testing.T) {
+	rules, err := ParseSampleRules([]byte(`
+- name: errors
+  when: status >= 400
+  rate: 1
+- name: misses
+  when: edge_result_type != "Hit"
+  rate: 1
+- name: hits
+  when: status < 300
+  rate: 0.1
+- name: everything-else
+  rate: 0
+`))
+	assert.NoError(t, err)
+
+	sampler := rules.New()
+
+	kept := make(map[string]int)
+
+	for i := 0; i < 10000; i++ {
+		for _, line := range []string{"200	Hit", "503	Error", "200	Miss", "301	Hit"} {
+			record, err := parser.ParseRecord(sampleFields, fmt.Sprintf("2020-06-18	03:38:13	%s	request-%d", line, i))
+			assert.NoError(t, err)
+
+			keep, err := sampler.Keep(record)
+			assert.NoError(t, err)
+
+			if keep {
+				kept[line]++
+				assert.Contains(t, record.Fields(), FieldSampleRate)
+			}
+		}
+	}
+
+	// Errors and misses are always kept, roughly a tenth of hits are kept.
+	assert.Equal(t, 10000, kept["503	Error"])
+	assert.Equal(t, 10000, kept["200	Miss"])
+	assert.InDelta(t, 1000, kept["200	Hit"], 100)
+	assert.Equal(t, 0, kept["301	Hit"])
+
+	assert.Equal(t, 10000-kept["200	Hit"], sampler.Counts()["hits"])
+	assert.Equal(t, 10000, sampler.Counts()["everything-else"])
+	assert.Equal(t, 20000-kept["200	Hit"], sampler.Dropped())
+}
+
+func TestSampler_Deterministic(t *testing.T) {
+	rules, err := ParseSampleRules([]byte(`[{rate: 0.5}]`))
+	assert.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		line := fmt.Sprintf("2020-06-18	03:38:13	200	Hit	request-%d", i)
+
+		first, err := parser.ParseRecord(sampleFields, line)
+		assert.NoError(t, err)
+		second, err := parser.ParseRecord(sampleFields, line)
+		assert.NoError(t, err)
+
+		// The same request is sampled the same way by different samplers, eg. when an object is processed again.
+		keepFirst, err := rules.New().Keep(first)
+		assert.NoError(t, err)
+		keepSecond, err := rules.New().Keep(second)
+		assert.NoError(t, err)
+		assert.Equal(t, keepFirst, keepSecond)
+
+		if keepFirst {
+			message, err := first.JSON()
+			assert.NoError(t, err)
+			assert.Contains(t, message, `"sample_rate":0.5`)
+		}
+	}
+}
+
+func TestParseSampleRules_Errors(t *testing.T) {
+	_, err := ParseSampleRules([]byte(`[{name: hits, rate: 1.5}]`))
+	assert.ErrorContains(t, err, "sampling rule hits has a rate outside of 0 to 1")
+
+	_, err = ParseSampleRules([]byte(`[{when: "status >", rate: 0.5}]`))
+	assert.ErrorContains(t, err, "sampling rule rule-1 is invalid")
+
+	_, err = ParseSampleRules([]byte(`{`))
+	assert.Error(t, err)
+
+	_, err = LoadSampleRules("testdata/missing.yaml")
+	assert.Error(t, err)
+}
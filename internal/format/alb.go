@@ -0,0 +1,224 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// NameALB is the name of the Application Load Balancer access logs format.
+const NameALB = "alb"
+
+var (
+	// albKey matches the name of objects written by Application Load Balancers, eg.
+	// 123456789012_elasticloadbalancing_us-east-2_app.my-loadbalancer.1234567890abcdef_20140215T2340Z_172.160.001.192_20sg8hgm.log.gz
+	albKey = regexp.MustCompile(`_elasticloadbalancing_[a-z0-9-]+_app\.`)
+	// albLine matches the start of an Application Load Balancer access log line.
+	albLine = regexp.MustCompile(`^(http|https|h2|grpcs|ws|wss) \d{4}-\d{2}-\d{2}T`)
+)
+
+// albFields are the values of an Application Load Balancer access log line, in order. Fields which mean the same as a
+// CloudFront field are named after it, so they can be used by enrichment, filters and redaction.
+var albFields = []string{
+	"type",
+	"time",
+	"elb",
+	"client:port",
+	"target:port",
+	"request_processing_time",
+	"target_processing_time",
+	"response_processing_time",
+	"sc-status",
+	"target_status_code",
+	"cs-bytes",
+	"sc-bytes",
+	"request",
+	"cs(User-Agent)",
+	"ssl-cipher",
+	"ssl-protocol",
+	"target_group_arn",
+	"trace_id",
+	"domain_name",
+	"chosen_cert_arn",
+	"matched_rule_priority",
+	"request_creation_time",
+	"actions_executed",
+	"redirect_url",
+	"error_reason",
+	"target:port_list",
+	"target_status_code_list",
+	"classification",
+	"classification_reason",
+	"conn_trace_id",
+}
+
+// albMinimumFields is the amount of fields in the oldest Application Load Balancer access logs.
+const albMinimumFields = 25
+
+// albDurations are the fields which are a number of seconds, or -1 if the request didn't reach that stage.
+var albDurations = map[string]bool{
+	"request_processing_time":  true,
+	"target_processing_time":   true,
+	"response_processing_time": true,
+}
+
+// albLists are the fields which are space separated lists.
+var albLists = map[string]bool{
+	"actions_executed":        true,
+	"target:port_list":        true,
+	"target_status_code_list": true,
+}
+
+func init() {
+	register(ALB{})
+}
+
+// ALB is the Application Load Balancer access logs format.
+type ALB struct{}
+
+// Name implements the interface.
+func (ALB) Name() string {
+	return NameALB
+}
+
+// Detect implements the interface.
+func (ALB) Detect(key string, head []byte) bool {
+	return albKey.MatchString(key) || albLine.Match(bytes.TrimLeft(head, " "))
+}
+
+// PartitionLayout implements the interface.
+func (ALB) PartitionLayout() string {
+	return "{yyyy}/{MM}/{dd}"
+}
+
+// NewParser implements the interface.
+func (f ALB) NewParser() Parser {
+	return f
+}
+
+// ParseHeader implements the interface. Application Load Balancer access logs have no header.
+func (ALB) ParseHeader(line string) bool {
+	return false
+}
+
+// ParseLine implements the interface.
+func (ALB) ParseLine(line string) (*parser.AccessLogRecord, error) {
+	values, err := splitFields(line)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", parser.ErrValue, err)
+	}
+
+	if len(values) < albMinimumFields {
+		return nil, fmt.Errorf("%w: got %d values, expected at least %d", parser.ErrShortLine, len(values), albMinimumFields)
+	}
+
+	record := &parser.AccessLogRecord{}
+
+	for i, value := range values {
+		if i >= len(albFields) {
+			// Fields added to the format since this parser was written.
+			if err := record.Set(fmt.Sprintf("field_%d", i+1), value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := setALBField(record, albFields[i], value); err != nil {
+			return nil, err
+		}
+	}
+
+	return record, nil
+}
+
+// setALBField sets a value of an Application Load Balancer access log line on the record.
+func setALBField(record *parser.AccessLogRecord, name, value string) error {
+	switch {
+	case name == "time":
+		timestamp, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("%w: %s", parser.ErrDate, value)
+		}
+		record.Timestamp = timestamp.UTC()
+		return nil
+	case name == "client:port":
+		ip, port := splitHostPort(value)
+		if err := record.Set("c-ip", ip); err != nil {
+			return err
+		}
+		return record.Set("c-port", port)
+	case name == "request":
+		return setALBRequest(record, value)
+	case albDurations[name]:
+		if value == parser.Empty {
+			record.SetValue(name, nil)
+			return nil
+		}
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %s is not a number", parser.ErrValue, value)
+		}
+		record.SetValue(name, seconds)
+		return nil
+	case albLists[name]:
+		if value == parser.Empty || value == "" {
+			record.SetValue(name, []string{})
+			return nil
+		}
+		record.SetValue(name, strings.Fields(value))
+		return nil
+	default:
+		return record.Set(name, value)
+	}
+}
+
+// setALBRequest splits the request line, eg. GET http://www.example.com:80/path?query HTTP/1.1, into its fields.
+func setALBRequest(record *parser.AccessLogRecord, request string) error {
+	method, rest, _ := strings.Cut(request, " ")
+	target, version, _ := strings.Cut(rest, " ")
+
+	if err := record.Set("cs-method", method); err != nil {
+		return err
+	}
+
+	scheme, host, path, query := target, "", "", ""
+
+	if u, err := url.Parse(target); err == nil && u.Host != "" {
+		scheme, host, path, query = u.Scheme, u.Hostname(), u.EscapedPath(), u.RawQuery
+	} else {
+		// Not a URL, keep it as it was logged.
+		scheme, path = "", target
+	}
+
+	fields := [][2]string{
+		{"cs-protocol", scheme},
+		{"cs(Host)", host},
+		{"cs-uri-stem", path},
+		{"cs-uri-query", query},
+		{"cs-protocol-version", version},
+	}
+
+	for _, field := range fields {
+		if err := record.Set(field[0], field[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitHostPort splits an address into its IP and port, allowing for IPv6 addresses with or without brackets.
+func splitHostPort(address string) (string, string) {
+	i := strings.LastIndexByte(address, ':')
+	if i < 0 || address == parser.Empty {
+		return address, ""
+	}
+
+	return strings.Trim(address[:i], "[]"), address[i+1:]
+}
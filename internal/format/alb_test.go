@@ -0,0 +1,96 @@
+package format
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+const testALBLine = `https 2018-07-02T22:23:00.186641Z app/my-loadbalancer/50dc6c495c0c9188 192.168.131.39:2817 10.0.0.1:80 0.086 0.048 0.037 200 200 0 57 "GET https://www.example.com:443/path/page?id=1&q=shoes HTTP/1.1" "curl/7.46.0 \"quoted\"" ECDHE-RSA-AES128-GCM-SHA256 TLSv1.2 arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067 "Root=1-58337281-1d84f3d73c47ec4e58577259" "www.example.com" "arn:aws:acm:us-east-2:123456789012:certificate/12345678-1234-1234-1234-123456789012" 1 2018-07-02T22:22:48.364000Z "authenticate,forward" "-" "-" "10.0.0.1:80 10.0.0.2:80" "502 200" "-" "-" TID_1234abcd5678ef90`
+
+func TestALB_Detect(t *testing.T) {
+	format := ALB{}
+
+	assert.True(t, format.Detect("AWSLogs/123456789012/elasticloadbalancing/us-east-2/2024/06/25/123456789012_elasticloadbalancing_us-east-2_app.my-loadbalancer.1234567890abcdef_20240625T2340Z_172.160.001.192_20sg8hgm.log.gz", nil))
+	assert.True(t, format.Detect("logs/alb.log", []byte(testALBLine)))
+	assert.False(t, format.Detect("logs/E38J4Y0L8GXH9D.2020-06-08-07.d51ccc94.gz", []byte("#Version: 1.0")))
+}
+
+func TestALB_ParseLine(t *testing.T) {
+	lineParser := ALB{}.NewParser()
+	assert.False(t, lineParser.ParseHeader(testALBLine))
+
+	record, err := lineParser.ParseLine(testALBLine)
+	assert.NoError(t, err)
+
+	assert.Equal(t, time.Date(2018, 7, 2, 22, 23, 0, 186641000, time.UTC), record.Timestamp)
+	assert.Equal(t, "192.168.131.39", record.ClientIP)
+	assert.Equal(t, int64(2817), *record.ClientPort)
+	assert.Equal(t, int64(200), *record.Status)
+	assert.Equal(t, int64(57), *record.SCBytes)
+	assert.Equal(t, "GET", record.Method)
+	assert.Equal(t, "https", record.Protocol)
+	assert.Equal(t, "www.example.com", record.Host)
+	assert.Equal(t, "/path/page", record.URIStem)
+	assert.Equal(t, "id=1&q=shoes", record.URIQuery)
+	assert.Equal(t, "HTTP/1.1", record.ProtocolVersion)
+	assert.Equal(t, `curl/7.46.0 "quoted"`, record.UserAgent)
+	assert.Equal(t, "TLSv1.2", record.SSLProtocol)
+	assert.Equal(t, "ECDHE-RSA-AES128-GCM-SHA256", record.SSLCipher)
+
+	message, err := record.JSON()
+	assert.NoError(t, err)
+
+	var fields map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(message), &fields))
+	assert.Equal(t, "https", fields["type"])
+	assert.Equal(t, "app/my-loadbalancer/50dc6c495c0c9188", fields["elb"])
+	assert.Equal(t, 0.086, fields["request_processing_time"])
+	assert.Equal(t, "200", fields["target_status_code"])
+	assert.Equal(t, []any{"authenticate,forward"}, fields["actions_executed"])
+	assert.Equal(t, []any{"10.0.0.1:80", "10.0.0.2:80"}, fields["target_port_list"])
+	assert.Equal(t, []any{"502", "200"}, fields["target_status_code_list"])
+	assert.Equal(t, "TID_1234abcd5678ef90", fields["conn_trace_id"])
+	assert.NotContains(t, fields, "time")
+
+	// The text output is tab separated.
+	assert.Contains(t, record.Message(), "	502,200	")
+}
+
+func TestALB_ParseLine_Errors(t *testing.T) {
+	lineParser := ALB{}.NewParser()
+
+	_, err := lineParser.ParseLine(`https 2018-07-02T22:23:00.186641Z app/my-loadbalancer/50dc6c495c0c9188`)
+	assert.ErrorIs(t, err, parser.ErrShortLine)
+
+	_, err = lineParser.ParseLine(`https 2018-07-02T22:23:00.186641Z "GET https://www.example.com`)
+	assert.ErrorIs(t, err, parser.ErrValue)
+
+	_, err = lineParser.ParseLine(`https yesterday app/my-loadbalancer/50dc6c495c0c9188 192.168.131.39:2817 10.0.0.1:80 0.086 0.048 0.037 200 200 0 57 "GET https://www.example.com:443/ HTTP/1.1" "curl/7.46.0" - - - "-" "-" "-" 0 - "-" "-" "-" "-" "-"`)
+	assert.ErrorIs(t, err, parser.ErrDate)
+
+	// Requests which didn't reach a target.
+	record, err := lineParser.ParseLine(`http 2018-07-02T22:23:00.186641Z app/my-loadbalancer/50dc6c495c0c9188 2001:db8::1:2817 - -1 -1 -1 460 - 34 0 "- http://www.example.com:80- -" "-" - - - "-" "-" "-" 0 2018-07-02T22:22:48.364000Z "-" "-" "-" "-" "-"`)
+	assert.NoError(t, err)
+	assert.Equal(t, "2001:db8::1", record.ClientIP)
+	assert.Equal(t, int64(460), *record.Status)
+	assert.Equal(t, float64(-1), record.Value("target_processing_time"))
+	assert.Equal(t, []string{}, record.Value("target:port_list"))
+}
+
+func TestSplitFields(t *testing.T) {
+	values, err := splitFields(`a "b c" [d e] "" "f \"g\" \\" h`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b c", "d e", "", `f "g" \`, "h"}, values)
+
+	_, err = splitFields(`a [b`)
+	assert.ErrorIs(t, err, ErrQuote)
+
+	// The rest of the line isn't included in the error.
+	_, err = splitFields(`a "token=abc`)
+	assert.EqualError(t, err, "unterminated quote at column 3")
+}
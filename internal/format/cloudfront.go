@@ -0,0 +1,120 @@
+package format
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// NameCloudFront is the name of the CloudFront standard logs format.
+const NameCloudFront = "cloudfront"
+
+// cloudFrontKey matches the name of objects written by CloudFront standard logging, eg. E38J4Y0L8GXH9D.2020-06-08-07.d51ccc94.gz
+var cloudFrontKey = regexp.MustCompile(`^E[A-Z0-9]+\.\d{4}-\d{2}-\d{2}-\d{2}\.[0-9a-f]+(\.gz)?$`)
+
+func init() {
+	register(CloudFront{})
+}
+
+// CloudFront standard logs, either tab separated lines described by directives or standard logging (v2) JSON lines.
+type CloudFront struct{}
+
+// Name implements the interface.
+func (CloudFront) Name() string {
+	return NameCloudFront
+}
+
+// Detect implements the interface.
+func (CloudFront) Detect(key string, head []byte) bool {
+	if cloudFrontKey.MatchString(path.Base(key)) {
+		return true
+	}
+
+	if bytes.HasPrefix(head, []byte(parser.DirectiveVersion)) || bytes.HasPrefix(head, []byte(parser.DirectiveFields)) {
+		return true
+	}
+
+	// Standard logging (v2) JSON objects are named after the CloudFront fields.
+	return bytes.HasPrefix(head, []byte("{")) && bytes.Contains(head, []byte(`"x-edge-`))
+}
+
+// PartitionLayout implements the interface. Standard logging (v2) is partitioned by the configured layout, if at all.
+func (CloudFront) PartitionLayout() string {
+	return ""
+}
+
+// NewParser implements the interface.
+func (CloudFront) NewParser() Parser {
+	return &cloudFrontParser{
+		header: parser.NewHeader(),
+	}
+}
+
+// cloudFrontParser parses the lines of a CloudFront standard log.
+type cloudFrontParser struct {
+	header *parser.Header
+}
+
+// ParseHeader implements the interface.
+func (p *cloudFrontParser) ParseHeader(line string) bool {
+	return p.header.ParseDirective(line)
+}
+
+// ParseLine implements the interface.
+func (p *cloudFrontParser) ParseLine(line string) (*parser.AccessLogRecord, error) {
+	if strings.HasPrefix(line, "{") {
+		return parser.ParseJSONRecord([]byte(line))
+	}
+
+	return parser.ParseRecord(p.header.Fields, line)
+}
+
+// CloudFrontRealtime logs delivered by a Kinesis data stream, which are tab separated lines without a header.
+type CloudFrontRealtime struct {
+	// Fields selected by the real-time log configuration, in order.
+	Fields []string
+}
+
+// NewCloudFrontRealtime creates the format for a real-time log configuration, defaulting to all fields.
+func NewCloudFrontRealtime(fields []string) CloudFrontRealtime {
+	if len(fields) == 0 {
+		fields = parser.RealtimeFields
+	}
+
+	return CloudFrontRealtime{
+		Fields: fields,
+	}
+}
+
+// Name implements the interface.
+func (CloudFrontRealtime) Name() string {
+	return "cloudfront-realtime"
+}
+
+// Detect implements the interface. Real-time logs are never stored as objects.
+func (CloudFrontRealtime) Detect(key string, head []byte) bool {
+	return false
+}
+
+// PartitionLayout implements the interface. Real-time logs are never stored as objects.
+func (CloudFrontRealtime) PartitionLayout() string {
+	return ""
+}
+
+// NewParser implements the interface.
+func (f CloudFrontRealtime) NewParser() Parser {
+	return f
+}
+
+// ParseHeader implements the interface. Real-time logs have no header.
+func (CloudFrontRealtime) ParseHeader(line string) bool {
+	return false
+}
+
+// ParseLine implements the interface.
+func (f CloudFrontRealtime) ParseLine(line string) (*parser.AccessLogRecord, error) {
+	return parser.ParseRecord(f.Fields, line)
+}
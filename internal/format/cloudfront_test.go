@@ -0,0 +1,66 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+func TestGet(t *testing.T) {
+	format, err := Get("CloudFront")
+	assert.NoError(t, err)
+	assert.Equal(t, CloudFront{}, format)
+
+	_, err = Get("apache")
+	assert.ErrorContains(t, err, "unsupported log format: apache")
+}
+
+func TestCloudFront_Detect(t *testing.T) {
+	format := CloudFront{}
+
+	assert.True(t, format.Detect("logs/E38J4Y0L8GXH9D.2020-06-08-07.d51ccc94.gz", nil))
+	assert.True(t, format.Detect("logs/access.log", []byte("#Version: 1.0\n#Fields: date time")))
+	assert.True(t, format.Detect("logs/access.log", []byte(`{"timestamp":"1719309601","x-edge-location":"SYD4-C2"}`)))
+	assert.False(t, format.Detect("logs/access.log", []byte(`{"timestamp":1719309601000,"action":"ALLOW"}`)))
+	assert.False(t, format.Detect("logs/access.log", []byte("http 2018-07-02T22:23:00.186641Z app/my-loadbalancer")))
+}
+
+func TestCloudFront_NewParser(t *testing.T) {
+	lineParser := CloudFront{}.NewParser()
+
+	assert.True(t, lineParser.ParseHeader("#Version: 1.0"))
+	assert.True(t, lineParser.ParseHeader("#Fields: date time x-edge-location sc-status"))
+	assert.False(t, lineParser.ParseHeader("2020-06-18	03:38:13	SYD4-C2	200"))
+
+	record, err := lineParser.ParseLine("2020-06-18	03:38:13	SYD4-C2	200")
+	assert.NoError(t, err)
+	assert.Equal(t, "SYD4-C2	200", record.Message())
+	assert.Equal(t, "2020-06-18T03:38:13Z", record.Timestamp.Format("2006-01-02T15:04:05Z07:00"))
+
+	record, err = lineParser.ParseLine(`{"timestamp":"1719309601","x-edge-location":"FRA2","sc-status":"404"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "FRA2", record.EdgeLocation)
+
+	_, err = lineParser.ParseLine("2020-06-18	03:38:13")
+	assert.ErrorIs(t, err, parser.ErrShortLine)
+
+	// Each object has its own header.
+	record, err = CloudFront{}.NewParser().ParseLine(parser.DefaultFields[0])
+	assert.ErrorIs(t, err, parser.ErrShortLine)
+	assert.Nil(t, record)
+}
+
+func TestCloudFrontRealtime(t *testing.T) {
+	format := NewCloudFrontRealtime(nil)
+	assert.Equal(t, parser.RealtimeFields, format.Fields)
+
+	lineParser := NewCloudFrontRealtime([]string{"timestamp", "c-ip", "sc-status"}).NewParser()
+	assert.False(t, lineParser.ParseHeader("1589496321.123	192.0.2.10	200"))
+
+	record, err := lineParser.ParseLine("1589496321.123	192.0.2.10	200")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1589496321123), record.Timestamp.UnixMilli())
+	assert.Equal(t, "192.0.2.10", record.ClientIP)
+}
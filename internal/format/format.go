@@ -0,0 +1,70 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// Format of a log source which can be shipped to CloudWatch Logs.
+type Format interface {
+	// Name of the format, as it is configured.
+	Name() string
+	// Detect returns true if an object with the key, which starts with the head, is in this format.
+	Detect(key string, head []byte) bool
+	// NewParser creates a parser for the lines of a single object.
+	NewParser() Parser
+	// PartitionLayout is the default layout of the date directories objects are written to, which are excluded from
+	// the log group, or empty if they have none.
+	PartitionLayout() string
+}
+
+// Parser parses the lines of a single object.
+type Parser interface {
+	// ParseHeader updates the parser from a header line, returning false if the line is not a header.
+	ParseHeader(line string) bool
+	// ParseLine parses a line into a record with a timestamp.
+	ParseLine(line string) (*parser.AccessLogRecord, error)
+}
+
+var (
+	// formats which can be configured, by name.
+	formats = map[string]Format{}
+	// detectable formats, in the order they are registered.
+	detectable []Format
+)
+
+// register a format so it can be configured by name and detected.
+func register(format Format) {
+	formats[format.Name()] = format
+	detectable = append(detectable, format)
+}
+
+// Get a format by its name.
+func Get(name string) (Format, error) {
+	format, ok := formats[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported log format: %s", name)
+	}
+
+	return format, nil
+}
+
+// Detect the format of an object from its key, or from its head if the key doesn't identify it. The fallback is
+// returned along with false if neither does.
+func Detect(key string, head []byte, fallback Format) (Format, bool) {
+	for _, format := range detectable {
+		if format.Detect(key, nil) {
+			return format, true
+		}
+	}
+
+	for _, format := range detectable {
+		if format.Detect("", head) {
+			return format, true
+		}
+	}
+
+	return fallback, false
+}
@@ -0,0 +1,36 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		key    string
+		head   string
+		format Format
+	}{
+		{"logs/E38J4Y0L8GXH9D.2020-06-08-07.d51ccc94.gz", "", CloudFront{}},
+		{"logs/123456789012_elasticloadbalancing_us-east-2_app.my-loadbalancer.1234567890abcdef_20240625T2340Z_172.160.001.192_20sg8hgm.log.gz", "", ALB{}},
+		{"logs/2019-02-06-00-00-38-5F5A6F3BD4C3A5F5", "", S3{}},
+		{"logs/123456789012_waflogs_cloudfront_my-web-acl_20240625T1040Z_a1b2c3d4.log.gz", "", WAF{}},
+		{"logs/access.log", "#Version: 1.0", CloudFront{}},
+		{"logs/access.log", testALBLine, ALB{}},
+		{"logs/access.log", testS3Line, S3{}},
+		{"logs/access.log", testWAFLine, WAF{}},
+		// The key is preferred over the head.
+		{"logs/E38J4Y0L8GXH9D.2020-06-08-07.d51ccc94.gz", testWAFLine, CloudFront{}},
+	}
+
+	for _, test := range tests {
+		format, ok := Detect(test.key, []byte(test.head), nil)
+		assert.True(t, ok, test.key)
+		assert.Equal(t, test.format, format, test.key)
+	}
+
+	format, ok := Detect("logs/access.log", []byte("127.0.0.1 - - [10/Oct/2000:13:55:36 -0700]"), ALB{})
+	assert.False(t, ok)
+	assert.Equal(t, ALB{}, format)
+}
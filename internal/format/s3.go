@@ -0,0 +1,182 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// NameS3 is the name of the S3 server access logs format.
+const NameS3 = "s3"
+
+// s3TimeLayout is the layout of the bracketed timestamp of an S3 server access log line, eg. 06/Feb/2019:00:00:38 +0000.
+const s3TimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+var (
+	// s3Key matches the name of objects written by S3 server access logging, eg. 2019-02-06-00-00-38-5F5A6F3BD4C3A5F5.
+	s3Key = regexp.MustCompile(`(^|/)\d{4}-\d{2}-\d{2}-\d{2}-\d{2}-\d{2}-[0-9A-F]{16}$`)
+	// s3Line matches the start of an S3 server access log line, which is the bucket owner, bucket and time.
+	s3Line = regexp.MustCompile(`^\S+ \S+ \[\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] `)
+)
+
+// s3Fields are the values of an S3 server access log line, in order. Fields which mean the same as a CloudFront field
+// are named after it, so they can be used by enrichment, filters and redaction.
+var s3Fields = []string{
+	"bucket_owner",
+	"bucket",
+	"time",
+	"c-ip",
+	"requester",
+	"request_id",
+	"operation",
+	"key",
+	"request_uri",
+	"sc-status",
+	"error_code",
+	"sc-bytes",
+	"object_size",
+	"total_time",
+	"turn_around_time",
+	"cs(Referer)",
+	"cs(User-Agent)",
+	"version_id",
+	"host_id",
+	"signature_version",
+	"ssl-cipher",
+	"authentication_type",
+	"cs(Host)",
+	"ssl-protocol",
+	"access_point_arn",
+	"acl_required",
+}
+
+// s3MinimumFields is the amount of fields in the oldest S3 server access logs.
+const s3MinimumFields = 18
+
+// s3Numbers are the fields which are a number of bytes or milliseconds.
+var s3Numbers = map[string]bool{
+	"object_size":      true,
+	"total_time":       true,
+	"turn_around_time": true,
+}
+
+func init() {
+	register(S3{})
+}
+
+// S3 is the S3 server access logs format.
+type S3 struct{}
+
+// Name implements the interface.
+func (S3) Name() string {
+	return NameS3
+}
+
+// Detect implements the interface.
+func (S3) Detect(key string, head []byte) bool {
+	return s3Key.MatchString(key) || s3Line.Match(bytes.TrimLeft(head, " "))
+}
+
+// PartitionLayout implements the interface, for objects written with date-based partitioning.
+func (S3) PartitionLayout() string {
+	return "{yyyy}/{MM}/{dd}"
+}
+
+// NewParser implements the interface.
+func (f S3) NewParser() Parser {
+	return f
+}
+
+// ParseHeader implements the interface. S3 server access logs have no header.
+func (S3) ParseHeader(line string) bool {
+	return false
+}
+
+// ParseLine implements the interface.
+func (S3) ParseLine(line string) (*parser.AccessLogRecord, error) {
+	values, err := splitFields(line)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", parser.ErrValue, err)
+	}
+
+	if len(values) < s3MinimumFields {
+		return nil, fmt.Errorf("%w: got %d values, expected at least %d", parser.ErrShortLine, len(values), s3MinimumFields)
+	}
+
+	record := &parser.AccessLogRecord{}
+
+	for i, value := range values {
+		if i >= len(s3Fields) {
+			// Fields added to the format since this parser was written.
+			if err := record.Set(fmt.Sprintf("field_%d", i+1), value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := setS3Field(record, s3Fields[i], value); err != nil {
+			return nil, err
+		}
+	}
+
+	return record, nil
+}
+
+// setS3Field sets a value of an S3 server access log line on the record.
+func setS3Field(record *parser.AccessLogRecord, name, value string) error {
+	switch {
+	case name == "time":
+		timestamp, err := time.Parse(s3TimeLayout, value)
+		if err != nil {
+			return fmt.Errorf("%w: %s", parser.ErrDate, value)
+		}
+		record.Timestamp = timestamp.UTC()
+		return nil
+	case name == "request_uri":
+		return setS3Request(record, value)
+	case s3Numbers[name]:
+		if value == parser.Empty {
+			record.SetValue(name, nil)
+			return nil
+		}
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %s is not a number", parser.ErrValue, value)
+		}
+		record.SetValue(name, number)
+		return nil
+	default:
+		return record.Set(name, value)
+	}
+}
+
+// setS3Request splits the request line, eg. GET /bucket/key?versionId=1 HTTP/1.1, into its fields.
+func setS3Request(record *parser.AccessLogRecord, request string) error {
+	if request == parser.Empty {
+		request = ""
+	}
+
+	method, rest, _ := strings.Cut(request, " ")
+	target, version, _ := strings.Cut(rest, " ")
+	path, query, _ := strings.Cut(target, "?")
+
+	fields := [][2]string{
+		{"cs-method", method},
+		{"cs-uri-stem", path},
+		{"cs-uri-query", query},
+		{"cs-protocol-version", version},
+	}
+
+	for _, field := range fields {
+		if err := record.Set(field[0], field[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
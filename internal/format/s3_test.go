@@ -0,0 +1,79 @@
+package format
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+const testS3Line = `79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be awsexamplebucket1 [06/Feb/2019:00:00:38 +0000] 192.0.2.3 79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be 3E57427F3EXAMPLE REST.GET.VERSIONING - "GET /awsexamplebucket1?versioning HTTP/1.1" 200 - 113 - 7 - "-" "S3Console/0.4" - s9lzHYrFp76ZVxRcpX9+5cjAnEH2ROuNkd2BHfIa6UkFVdtjf5mKR3/eTPFvsiP/XV/VLi31234= SigV4 ECDHE-RSA-AES128-GCM-SHA256 AuthHeader awsexamplebucket1.s3.us-west-1.amazonaws.com TLSV1.2 arn:aws:s3:us-west-1:123456789012:accesspoint/example-AP Yes`
+
+func TestS3_Detect(t *testing.T) {
+	format := S3{}
+
+	assert.True(t, format.Detect("logs/2019-02-06-00-00-38-5F5A6F3BD4C3A5F5", nil))
+	assert.True(t, format.Detect("logs/access.log", []byte(testS3Line)))
+	assert.False(t, format.Detect("logs/E38J4Y0L8GXH9D.2020-06-08-07.d51ccc94.gz", []byte("#Version: 1.0")))
+	assert.False(t, format.Detect("logs/alb.log", []byte(testALBLine)))
+}
+
+func TestS3_ParseLine(t *testing.T) {
+	lineParser := S3{}.NewParser()
+	assert.False(t, lineParser.ParseHeader(testS3Line))
+
+	record, err := lineParser.ParseLine(testS3Line)
+	assert.NoError(t, err)
+
+	assert.Equal(t, time.Date(2019, 2, 6, 0, 0, 38, 0, time.UTC), record.Timestamp)
+	assert.Equal(t, "192.0.2.3", record.ClientIP)
+	assert.Equal(t, int64(200), *record.Status)
+	assert.Equal(t, int64(113), *record.SCBytes)
+	assert.Equal(t, "GET", record.Method)
+	assert.Equal(t, "/awsexamplebucket1", record.URIStem)
+	assert.Equal(t, "versioning", record.URIQuery)
+	assert.Equal(t, "HTTP/1.1", record.ProtocolVersion)
+	assert.Equal(t, "", record.Referer)
+	assert.Equal(t, "S3Console/0.4", record.UserAgent)
+	assert.Equal(t, "awsexamplebucket1.s3.us-west-1.amazonaws.com", record.Host)
+	assert.Equal(t, "TLSV1.2", record.SSLProtocol)
+
+	message, err := record.JSON()
+	assert.NoError(t, err)
+
+	var fields map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(message), &fields))
+	assert.Equal(t, "awsexamplebucket1", fields["bucket"])
+	assert.Equal(t, "REST.GET.VERSIONING", fields["operation"])
+	assert.Equal(t, float64(7), fields["total_time"])
+	assert.Nil(t, fields["object_size"])
+	assert.Nil(t, fields["turn_around_time"])
+	assert.Equal(t, "Yes", fields["acl_required"])
+	assert.NotContains(t, fields, "time")
+}
+
+func TestS3_ParseLine_Errors(t *testing.T) {
+	lineParser := S3{}.NewParser()
+
+	_, err := lineParser.ParseLine(`79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be awsexamplebucket1 [06/Feb/2019:00:00:38 +0000] 192.0.2.3`)
+	assert.ErrorIs(t, err, parser.ErrShortLine)
+
+	_, err = lineParser.ParseLine(`79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be awsexamplebucket1 [06/Feb/2019:00:00:38 +0000`)
+	assert.ErrorIs(t, err, parser.ErrValue)
+
+	_, err = lineParser.ParseLine(`owner bucket [yesterday] 192.0.2.3 - 3E57427F3EXAMPLE REST.GET.OBJECT key "GET /bucket/key HTTP/1.1" 200 - 113 113 7 6 "-" "curl/7.68.0" -`)
+	assert.ErrorIs(t, err, parser.ErrDate)
+
+	_, err = lineParser.ParseLine(`owner bucket [06/Feb/2019:00:00:38 +0000] 192.0.2.3 - 3E57427F3EXAMPLE REST.GET.OBJECT key "GET /bucket/key HTTP/1.1" 200 - 113 lots 7 6 "-" "curl/7.68.0" -`)
+	assert.ErrorIs(t, err, parser.ErrValue)
+
+	// Requests without a request line, eg. lifecycle operations.
+	record, err := lineParser.ParseLine(`owner bucket [06/Feb/2019:00:00:38 +0000] - AmazonS3 3E57427F3EXAMPLE S3.EXPIRE.OBJECT key "-" - - - 113 - - "-" "-" -`)
+	assert.NoError(t, err)
+	assert.Equal(t, "", record.Method)
+	assert.Nil(t, record.Status)
+	assert.Equal(t, int64(113), record.Value("object_size"))
+}
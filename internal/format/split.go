@@ -0,0 +1,64 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrQuote is returned when a line has a quoted or bracketed value which isn't closed.
+var ErrQuote = errors.New("unterminated quote")
+
+// quoteError reports the column of an unterminated quote rather than the rest of the line, which may hold sensitive
+// values and is kept in quarantine entries.
+func quoteError(i int) error {
+	return fmt.Errorf("%w at column %d", ErrQuote, i+1)
+}
+
+// splitFields splits a space delimited line into its values, keeping quoted and bracketed values together and
+// removing their quotes and brackets. Quotes can be escaped with a backslash inside a quoted value.
+func splitFields(line string) ([]string, error) {
+	var values []string
+
+	for i := 0; i < len(line); {
+		switch line[i] {
+		case ' ':
+			i++
+		case '"':
+			var value strings.Builder
+
+			end := i + 1
+			for ; end < len(line) && line[end] != '"'; end++ {
+				if line[end] == '\\' && end+1 < len(line) && (line[end+1] == '"' || line[end+1] == '\\') {
+					end++
+				}
+				value.WriteByte(line[end])
+			}
+
+			if end >= len(line) {
+				return nil, quoteError(i)
+			}
+
+			values = append(values, value.String())
+			i = end + 1
+		case '[':
+			end := strings.IndexByte(line[i:], ']')
+			if end < 0 {
+				return nil, quoteError(i)
+			}
+
+			values = append(values, line[i+1:i+end])
+			i += end + 1
+		default:
+			end := strings.IndexByte(line[i:], ' ')
+			if end < 0 {
+				end = len(line) - i
+			}
+
+			values = append(values, line[i:i+end])
+			i += end
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,237 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/textproto"
+	"regexp"
+	"time"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// NameWAF is the name of the AWS WAF web ACL logs format.
+const NameWAF = "waf"
+
+var (
+	// wafKey matches the name of objects written by AWS WAF logging, eg.
+	// 123456789012_waflogs_us-east-1_my-web-acl_20240625T2340Z_a1b2c3d4.log.gz
+	wafKey = regexp.MustCompile(`_waflogs_[a-z0-9-]+_`)
+	// wafObject is a field which is only in AWS WAF log objects.
+	wafObject = []byte(`"webaclId"`)
+)
+
+// wafRequestFields are the fields of the httpRequest object which mean the same as a CloudFront field.
+var wafRequestFields = map[string]string{
+	"clientIp":    "c-ip",
+	"country":     "c-country",
+	"uri":         "cs-uri-stem",
+	"args":        "cs-uri-query",
+	"httpVersion": "cs-protocol-version",
+	"httpMethod":  "cs-method",
+	"requestId":   "x-edge-request-id",
+}
+
+// wafHeaderFields are the request headers which mean the same as a CloudFront field other than cs(Name), so they are
+// anonymised and enriched in the same way.
+var wafHeaderFields = map[string]string{
+	"X-Forwarded-For": "x-forwarded-for",
+}
+
+func init() {
+	register(WAF{})
+}
+
+// WAF is the AWS WAF web ACL logs format, which is newline delimited JSON.
+type WAF struct{}
+
+// Name implements the interface.
+func (WAF) Name() string {
+	return NameWAF
+}
+
+// Detect implements the interface.
+func (WAF) Detect(key string, head []byte) bool {
+	return wafKey.MatchString(key) || (bytes.HasPrefix(bytes.TrimLeft(head, " "), []byte("{")) && bytes.Contains(head, wafObject))
+}
+
+// PartitionLayout implements the interface. AWS WAF logs are partitioned by the minute.
+func (WAF) PartitionLayout() string {
+	return "{yyyy}/{MM}/{dd}/{HH}/{mm}"
+}
+
+// NewParser implements the interface.
+func (f WAF) NewParser() Parser {
+	return f
+}
+
+// ParseHeader implements the interface. AWS WAF logs have no header.
+func (WAF) ParseHeader(line string) bool {
+	return false
+}
+
+// wafRequest is the httpRequest object of an AWS WAF log.
+type wafRequest struct {
+	fields  []string
+	values  map[string]any
+	headers []wafHeader
+}
+
+// wafHeader is a request header of an AWS WAF log.
+type wafHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ParseLine implements the interface.
+//
+// Fields are kept in the order they appear in the object. The timestamp is taken from the epoch milliseconds of the
+// timestamp field, and the httpRequest object is flattened into the fields which mean the same in CloudFront logs,
+// with each header as a cs(Name) field, or x-forwarded-for, so they can be used by enrichment, filters and redaction.
+// Other fields keep their name, eg. terminatingRuleId or httpRequest.host, as their JSON key.
+func (WAF) ParseLine(line string) (*parser.AccessLogRecord, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(line)))
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", parser.ErrJSON, err)
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("%w: expected an object", parser.ErrJSON)
+	}
+
+	record := &parser.AccessLogRecord{}
+
+	var found bool
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", parser.ErrJSON, err)
+		}
+
+		name, ok := token.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: expected a field name", parser.ErrJSON)
+		}
+
+		switch name {
+		case "timestamp":
+			var millis int64
+			if err := decoder.Decode(&millis); err != nil {
+				return nil, fmt.Errorf("%w: timestamp is not epoch milliseconds: %s", parser.ErrDate, err)
+			}
+			record.Timestamp = time.UnixMilli(millis).UTC()
+			found = true
+		case "httpRequest":
+			var request wafRequest
+			if err := decoder.Decode(&request); err != nil {
+				return nil, fmt.Errorf("%w: field %s: %s", parser.ErrJSON, name, err)
+			}
+			if err := request.set(record); err != nil {
+				return nil, err
+			}
+		default:
+			var value any
+			if err := decoder.Decode(&value); err != nil {
+				return nil, fmt.Errorf("%w: field %s: %s", parser.ErrJSON, name, err)
+			}
+			record.SetNamedValue(name, value)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%w: no timestamp", parser.ErrDate)
+	}
+
+	return record, nil
+}
+
+// UnmarshalJSON decodes the httpRequest object, keeping the order of its fields.
+func (r *wafRequest) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("expected an object")
+	}
+
+	r.values = make(map[string]any)
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		name, ok := token.(string)
+		if !ok {
+			return fmt.Errorf("expected a field name")
+		}
+
+		if name == "headers" {
+			if err := decoder.Decode(&r.headers); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var value any
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+
+		r.fields = append(r.fields, name)
+		r.values[name] = value
+	}
+
+	return nil
+}
+
+// set the fields of the request on the record.
+func (r *wafRequest) set(record *parser.AccessLogRecord) error {
+	for _, name := range r.fields {
+		value := r.values[name]
+
+		field, ok := wafRequestFields[name]
+		if !ok {
+			record.SetNamedValue("httpRequest."+name, value)
+			continue
+		}
+
+		if value == nil {
+			value = parser.Empty
+		}
+
+		if err := record.Set(field, fmt.Sprint(value)); err != nil {
+			return err
+		}
+	}
+
+	for _, header := range r.headers {
+		canonical := textproto.CanonicalMIMEHeaderKey(header.Name)
+
+		name, ok := wafHeaderFields[canonical]
+		if !ok {
+			name = fmt.Sprintf("cs(%s)", canonical)
+		}
+
+		value := header.Value
+		if previous, ok := record.Value(name).(string); ok {
+			// Repeated headers are combined.
+			value = previous + "," + value
+		}
+
+		if err := record.Set(name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
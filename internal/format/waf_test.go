@@ -0,0 +1,83 @@
+package format
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+const testWAFLine = `{"timestamp":1576280412771,"formatVersion":1,"webaclId":"arn:aws:wafv2:us-east-1:123456789012:global/webacl/my-web-acl/a1b2c3d4","terminatingRuleId":"AWS-AWSManagedRulesSQLiRuleSet","terminatingRuleType":"MANAGED_RULE_GROUP","action":"BLOCK","terminatingRuleMatchDetails":[{"conditionType":"SQL_INJECTION","location":"QUERY_STRING","matchedData":["1","or","1"]}],"httpSourceName":"CF","httpSourceId":"E38J4Y0L8GXH9D","ruleGroupList":[],"rateBasedRuleList":[],"nonTerminatingMatchingRules":[],"requestHeadersInserted":null,"responseCodeSent":403,"httpRequest":{"clientIp":"192.0.2.10","country":"AU","headers":[{"name":"host","value":"www.example.com"},{"name":"user-agent","value":"curl/7.68.0"},{"name":"accept","value":"text/html"},{"name":"Accept","value":"*/*"},{"name":"x-forwarded-for","value":"198.51.100.7"}],"uri":"/search","args":"q=1%20or%201","httpVersion":"HTTP/2.0","httpMethod":"GET","scheme":"https","requestId":"oe49fbR4FcmNWieL3CVBnkQFZiNls0O9Zg24IfUYPWOXMX36hqQI4g=="},"labels":[{"name":"awswaf:managed:aws:sql-database:SQLi_QueryArguments"}]}`
+
+func TestWAF_Detect(t *testing.T) {
+	format := WAF{}
+
+	assert.True(t, format.Detect("AWSLogs/123456789012/WAFLogs/cloudfront/my-web-acl/2024/06/25/10/40/123456789012_waflogs_cloudfront_my-web-acl_20240625T1040Z_a1b2c3d4.log.gz", nil))
+	assert.True(t, format.Detect("logs/waf.log", []byte(testWAFLine)))
+	assert.False(t, format.Detect("logs/access.log", []byte(`{"timestamp":"1719309601","x-edge-location":"SYD4-C2"}`)))
+	assert.False(t, format.Detect("logs/access.log", []byte("#Version: 1.0")))
+}
+
+func TestWAF_ParseLine(t *testing.T) {
+	lineParser := WAF{}.NewParser()
+	assert.False(t, lineParser.ParseHeader(testWAFLine))
+
+	record, err := lineParser.ParseLine(testWAFLine)
+	assert.NoError(t, err)
+
+	assert.Equal(t, time.UnixMilli(1576280412771).UTC(), record.Timestamp)
+	assert.Equal(t, "192.0.2.10", record.ClientIP)
+	assert.Equal(t, "GET", record.Method)
+	assert.Equal(t, "/search", record.URIStem)
+	assert.Equal(t, "q=1%20or%201", record.URIQuery)
+	assert.Equal(t, "HTTP/2.0", record.ProtocolVersion)
+	assert.Equal(t, "www.example.com", record.Host)
+	assert.Equal(t, "curl/7.68.0", record.UserAgent)
+	assert.Equal(t, "oe49fbR4FcmNWieL3CVBnkQFZiNls0O9Zg24IfUYPWOXMX36hqQI4g==", record.EdgeRequestID)
+	assert.Equal(t, "AWS-AWSManagedRulesSQLiRuleSet", record.Value("terminatingRuleId"))
+	assert.Equal(t, "BLOCK", record.Value("action"))
+	assert.Equal(t, "text/html,*/*", record.Value("cs(Accept)"))
+	assert.Equal(t, "198.51.100.7", record.ForwardedFor)
+	assert.Nil(t, record.Value("cs(X-Forwarded-For)"))
+	assert.Equal(t, "AU", record.Value("c-country"))
+	assert.Equal(t, float64(403), record.Value("responseCodeSent"))
+	assert.Nil(t, record.Value("requestHeadersInserted"))
+
+	message, err := record.JSON()
+	assert.NoError(t, err)
+
+	var fields map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(message), &fields))
+	assert.Equal(t, "BLOCK", fields["action"])
+	assert.Equal(t, "www.example.com", fields["host"])
+	assert.Equal(t, "AWS-AWSManagedRulesSQLiRuleSet", fields["terminatingRuleId"])
+	assert.Equal(t, []any{}, fields["ruleGroupList"])
+	assert.Equal(t, "https", fields["httpRequest.scheme"])
+	assert.Equal(t, "QUERY_STRING", fields["terminatingRuleMatchDetails"].([]any)[0].(map[string]any)["location"])
+	assert.NotContains(t, fields, "timestamp")
+
+	// Nested values are JSON in the text output.
+	assert.Contains(t, record.Message(), `[{"name":"awswaf:managed:aws:sql-database:SQLi_QueryArguments"}]`)
+}
+
+func TestWAF_ParseLine_Errors(t *testing.T) {
+	lineParser := WAF{}.NewParser()
+
+	_, err := lineParser.ParseLine(`{"timestamp":1576280412771,"action":`)
+	assert.ErrorIs(t, err, parser.ErrJSON)
+
+	_, err = lineParser.ParseLine(`["not", "an", "object"]`)
+	assert.ErrorIs(t, err, parser.ErrJSON)
+
+	_, err = lineParser.ParseLine(`{"timestamp":"yesterday","action":"ALLOW"}`)
+	assert.ErrorIs(t, err, parser.ErrDate)
+
+	_, err = lineParser.ParseLine(`{"action":"ALLOW"}`)
+	assert.ErrorIs(t, err, parser.ErrDate)
+
+	_, err = lineParser.ParseLine(`{"timestamp":1576280412771,"httpRequest":{"headers":"none"}}`)
+	assert.ErrorIs(t, err, parser.ErrJSON)
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/config"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/enrich"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/processor"
+)
+
+// loadPOPs loads the table of edge locations if it is used by the config.
+func loadPOPs(cfg config.Config) (*enrich.POPs, error) {
+	if !cfg.EnrichPOP && !cfg.POPSummary {
+		return nil, nil
+	}
+
+	pops, err := enrich.LoadPOPs(cfg.POPTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load POP table: %w", err)
+	}
+
+	return pops, nil
+}
+
+// loadGeoIP loads the GeoIP databases if they are configured.
+func loadGeoIP(cfg config.Config) (*enrich.GeoIP, error) {
+	if cfg.GeoIPDatabase == "" && cfg.ASNDatabase == "" {
+		return nil, nil
+	}
+
+	geoIP, err := enrich.LoadGeoIP(cfg.GeoIPDatabase, cfg.ASNDatabase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load GeoIP databases: %w", err)
+	}
+
+	return geoIP, nil
+}
+
+// newEnrichers creates the enrichers which are enabled by the config, in the order they are applied.
+func newEnrichers(cfg config.Config, pops *enrich.POPs, geoIP *enrich.GeoIP) ([]processor.Enricher, error) {
+	var enrichers []processor.Enricher
+
+	if cfg.EnrichUserAgent {
+		userAgents, err := enrich.LoadUserAgentParser(cfg.UserAgentRegexes, cfg.UserAgentCacheSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load user agent parser: %w", err)
+		}
+		enrichers = append(enrichers, userAgents)
+	}
+
+	if geoIP != nil {
+		enrichers = append(enrichers, geoIP)
+	}
+
+	if cfg.EnrichPOP {
+		enrichers = append(enrichers, pops)
+	}
+
+	return enrichers, nil
+}
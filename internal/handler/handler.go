@@ -1,93 +1,679 @@
 package handler
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
-	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
-	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/config"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/enrich"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/filter"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/format"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/ledger"
 	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
 	"github.com/skpr/cloudfront-cloudwatchlogs/internal/processor"
 	"github.com/skpr/cloudfront-cloudwatchlogs/internal/pusher"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/quarantine"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/redact"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/types"
 	"github.com/skpr/cloudfront-cloudwatchlogs/internal/utils"
 )
 
 const (
 	// LogStreamName is the name of the log stream where all events will be pushed to.
 	LogStreamName = "cloudfront"
+	// QuarantineStreamName is the name of the log stream where lines which can't be parsed are pushed to.
+	QuarantineStreamName = "quarantine"
+	// SummaryStreamName is the name of the log stream where summaries of each edge location are pushed to.
+	SummaryStreamName = "summary"
 )
 
 // EventHandler defines the event handler.
 type EventHandler struct {
-	log            *slog.Logger
-	downloadClient manager.DownloadAPIClient
-	cwLogsClient   *cloudwatchlogs.Client
-	batchSize      int
+	log              *slog.Logger
+	s3Client         types.S3Interface
+	cwLogsClient     types.CloudwatchLogsInterface
+	batchSize        int
+	concurrency      int
+	ledger           ledger.Ledger
+	leaseDuration    time.Duration
+	options          processor.Options
+	detectFormat     bool
+	realtimeGroup    string
+	realtimeGroups   map[string]string
+	layout           string
+	hivePartitions   bool
+	quarantine       config.QuarantineDestination
+	quarantineBucket string
+	quarantinePrefix string
+	pops             *enrich.POPs
+	geoIP            *enrich.GeoIP
+	popSummary       bool
+	redaction        *redact.Rules
+	anonymisation    redact.Mode
+	anonymisationFor map[string]redact.Mode
+	anonymisationKey []byte
+	filters          *filter.Rules
+	sampling         *filter.SampleRules
 }
 
 // NewEventHandler creates a new event handler.
-func NewEventHandler(log *slog.Logger, downloadClient manager.DownloadAPIClient, cwLogsClient *cloudwatchlogs.Client, batchSize int) *EventHandler {
+func NewEventHandler(log *slog.Logger, s3Client types.S3Interface, cwLogsClient types.CloudwatchLogsInterface, objectLedger ledger.Ledger, cfg config.Config) (*EventHandler, error) {
+	pops, err := loadPOPs(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	geoIP, err := loadGeoIP(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	enrichers, err := newEnrichers(cfg, pops, geoIP)
+	if err != nil {
+		return nil, err
+	}
+
+	var filters *filter.Rules
+
+	if cfg.FilterRules != "" {
+		filters, err = filter.Load(cfg.FilterRules)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var sampling *filter.SampleRules
+
+	if cfg.SamplingRules != "" {
+		sampling, err = filter.LoadSampleRules(cfg.SamplingRules)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var redaction *redact.Rules
+
+	if cfg.RedactionRules != "" {
+		redaction, err = redact.Load(cfg.RedactionRules)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &EventHandler{
-		log:            log,
-		downloadClient: downloadClient,
-		cwLogsClient:   cwLogsClient,
-		batchSize:      batchSize,
+		log:           log,
+		s3Client:      s3Client,
+		cwLogsClient:  cwLogsClient,
+		batchSize:     cfg.BatchSize,
+		concurrency:   cfg.Concurrency,
+		ledger:        objectLedger,
+		leaseDuration: cfg.LedgerLease,
+		options: processor.Options{
+			Format:      cfg.Format,
+			Output:      cfg.Output,
+			Decode:      cfg.Decode,
+			Fields:      cfg.RealtimeFields,
+			Timestamp:   cfg.Timestamp,
+			Unparseable: cfg.Unparseable,
+			Enrichers:   enrichers,
+			Projection: processor.Projection{
+				Include: cfg.IncludeFields,
+				Exclude: cfg.ExcludeFields,
+			},
+		},
+		detectFormat:     cfg.DetectFormat,
+		realtimeGroup:    cfg.RealtimeLogGroup,
+		realtimeGroups:   cfg.RealtimeLogGroups,
+		layout:           cfg.PartitionLayout,
+		hivePartitions:   cfg.HivePartitions,
+		quarantine:       cfg.Quarantine,
+		quarantineBucket: cfg.QuarantineBucket,
+		quarantinePrefix: cfg.QuarantinePrefix,
+		pops:             pops,
+		geoIP:            geoIP,
+		popSummary:       cfg.POPSummary,
+		redaction:        redaction,
+		anonymisation:    cfg.Anonymisation,
+		anonymisationFor: cfg.AnonymisationLogGroups,
+		anonymisationKey: []byte(cfg.AnonymisationKey),
+		filters:          filters,
+		sampling:         sampling,
+	}, nil
+}
+
+// HandleRecords handles the objects of s3 event records, processing up to the configured concurrency at once. Each
+// object is processed independently, so one which fails doesn't stop the others, and the errors of all the objects
+// which failed are returned together.
+func (h *EventHandler) HandleRecords(ctx context.Context, records []events.S3EventRecord) error {
+	return errors.Join(h.handleRecords(ctx, records)...)
+}
+
+// handleRecords handles the objects of s3 event records, returning the error of each record in order.
+func (h *EventHandler) handleRecords(ctx context.Context, records []events.S3EventRecord) []error {
+	h.log.Info(fmt.Sprintf("Processing %d objects, %d at a time", len(records), h.concurrency))
+
+	errs := forEach(len(records), h.concurrency, func(i int) error {
+		record := records[i]
+
+		if err := h.HandleEvent(ctx, record); err != nil {
+			h.log.Error(fmt.Sprintf("Failed to process %s from %s", record.S3.Object.Key, record.S3.Bucket.Name), "bucket", record.S3.Bucket.Name, "key", record.S3.Object.Key, "error", err)
+			return fmt.Errorf("failed to process %s from %s: %w", record.S3.Object.Key, record.S3.Bucket.Name, err)
+		}
+
+		return nil
+	})
+
+	if failed := countErrors(errs); failed > 0 {
+		h.log.Error(fmt.Sprintf("Failed to process %d of %d objects", failed, len(records)))
+	}
+
+	return errs
+}
+
+// countErrors returns the amount of errors which aren't nil.
+func countErrors(errs []error) int {
+	var count int
+
+	for _, err := range errs {
+		if err != nil {
+			count++
+		}
 	}
+
+	return count
 }
 
 // HandleEvent handles the event.
 func (h *EventHandler) HandleEvent(ctx context.Context, record events.S3EventRecord) error {
 	key := record.S3.Object.Key
 	bucket := record.S3.Bucket.Name
-	h.log.Info(fmt.Sprintf("Downloading logs %s from s3 bucket %s", key, bucket))
-	downloader := manager.NewDownloader(h.downloadClient)
-	gzipBuff := manager.NewWriteAtBuffer([]byte{})
-	n, err := downloader.Download(ctx, gzipBuff, &s3.GetObjectInput{
+
+	// Objects may be processed at the same time, so each of their logs says which object it is for.
+	h = h.forObject(bucket, key)
+
+	if h.ledger == nil {
+		return h.fetchObject(ctx, bucket, key, nil)
+	}
+
+	var object *s3.GetObjectOutput
+
+	// The lease is acquired before the object is fetched, so objects which have been ingested, or are being ingested
+	// elsewhere, aren't fetched at all. Notifications without the ETag of the object fall back to fetching it first.
+	etag := record.S3.Object.ETag
+	if etag == "" {
+		var err error
+
+		object, err = h.getObject(ctx, bucket, key)
+		if err != nil {
+			return err
+		}
+		defer object.Body.Close()
+
+		etag = aws.ToString(object.ETag)
+	}
+
+	lease, err := h.ledger.Acquire(ctx, ledger.NewObject(bucket, key, etag), h.leaseDuration)
+	if errors.Is(err, ledger.ErrComplete) {
+		h.log.Info(fmt.Sprintf("Skipping %s which has already been ingested", key))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := h.fetchObject(ctx, bucket, key, object); err != nil {
+		if err := h.ledger.Release(ctx, lease); err != nil {
+			h.log.Error(fmt.Sprintf("Failed to release lease on %s", key), "error", err)
+		}
+		return err
+	}
+
+	err = h.ledger.Complete(ctx, lease)
+	if errors.Is(err, ledger.ErrLeaseLost) {
+		h.log.Warn(fmt.Sprintf("Lease on %s expired before it was ingested, so it may have been ingested more than once", key))
+		return nil
+	}
+
+	return err
+}
+
+// fetchObject processes an object, getting it from s3 first unless it already has been.
+func (h *EventHandler) fetchObject(ctx context.Context, bucket, key string, object *s3.GetObjectOutput) error {
+	if object == nil {
+		var err error
+
+		object, err = h.getObject(ctx, bucket, key)
+		if err != nil {
+			return err
+		}
+		defer object.Body.Close()
+	}
+
+	return h.processObject(ctx, bucket, key, object)
+}
+
+// getObject gets an object from s3, which is streamed from its body.
+func (h *EventHandler) getObject(ctx context.Context, bucket, key string) (*s3.GetObjectOutput, error) {
+	h.log.Info(fmt.Sprintf("Streaming logs %s from s3 bucket %s", key, bucket))
+	object, err := h.s3Client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to download %s from %s: %w", key, bucket, err)
+		return nil, fmt.Errorf("failed to get %s from %s: %w", key, bucket, err)
+	}
+	h.log.Info(fmt.Sprintf("Fetching %s from %s from %s", utils.ByteCountBinary(aws.ToInt64(object.ContentLength)), key, bucket))
+
+	return object, nil
+}
+
+// processObject pushes the events of an object to CloudWatch Logs.
+func (h *EventHandler) processObject(ctx context.Context, bucket, key string, object *s3.GetObjectOutput) error {
+	// The body is buffered so the start of it can be used to detect the format before it is processed.
+	body := bufio.NewReaderSize(object.Body, processor.HeadSize)
+
+	options := h.options
+
+	if h.detectFormat {
+		// The head is shorter if the object is, and any error reading it is returned when it is processed.
+		head, _ := body.Peek(processor.HeadSize)
+
+		logFormat, ok := processor.DetectFormat(key, head, options.Format)
+		if ok {
+			h.log.Info(fmt.Sprintf("Detected %s format", logFormat.Name()))
+		} else {
+			h.log.Info(fmt.Sprintf("Unable to detect format, falling back to %s", logFormat.Name()))
+		}
+		options.Format = logFormat
+	}
+
+	if options.Unparseable == processor.UnparseableLastModified {
+		options.LastModified = aws.ToTime(object.LastModified)
+	}
+
+	logGroup := parser.GetPartitionedLogGroupName(key, h.partitionLayout(options.Format), h.hivePartitions)
+
+	dest, err := h.newDestination(ctx, logGroup, key, h.quarantinePrefix+key+".ndjson")
+	if err != nil {
+		return err
+	}
+
+	h.log.Info("Processing logs")
+	options = dest.apply(options)
+	options.Quarantine = dest.quarantineFunc(ctx, bucket, key)
+	err = processor.ProcessReader(body, options, dest.push(ctx))
+	if err != nil {
+		return err
+	}
+
+	return h.flush(ctx, dest, key)
+}
+
+// partitionLayout returns the configured partition layout, or the default layout of the format if there is none.
+func (h *EventHandler) partitionLayout(logFormat format.Format) string {
+	if h.layout != "" || logFormat == nil {
+		return h.layout
+	}
+
+	return logFormat.PartitionLayout()
+}
+
+// forObject returns a copy of the handler which logs the bucket and key of the object being processed.
+func (h *EventHandler) forObject(bucket, key string) *EventHandler {
+	handler := *h
+	handler.log = h.log.With("bucket", bucket, "key", key)
+
+	return &handler
+}
+
+// HandleKinesisEvent handles CloudFront real-time logs delivered by a Kinesis data stream. Each record is pushed to the
+// log group of its host, or the real-time log group if its host has none. Records are processed in order until one
+// fails, which is returned so it is retried along with the records after it, without pushing the records before it
+// again.
+func (h *EventHandler) HandleKinesisEvent(ctx context.Context, event events.KinesisEvent) (events.KinesisEventResponse, error) {
+	h.log.Info(fmt.Sprintf("Processing %d real-time log records", len(event.Records)))
+
+	var (
+		// destinations of each log group, which are created when the first record for them is processed.
+		destinations = make(map[string]*destination)
+		failed       = len(event.Records)
+	)
+
+	for i, record := range event.Records {
+		if err := h.processKinesisRecord(ctx, record, destinations); err != nil {
+			h.log.Error(fmt.Sprintf("Failed to process record %s", record.EventID), "event_id", record.EventID, "sequence_number", record.Kinesis.SequenceNumber, "error", err)
+			failed = i
+			break
+		}
+	}
+
+	for _, logGroup := range slices.Sorted(maps.Keys(destinations)) {
+		if err := h.flush(ctx, destinations[logGroup], logGroup); err != nil {
+			// The events of any of the records may not have been pushed, so they are all retried.
+			return events.KinesisEventResponse{}, err
+		}
+	}
+
+	var response events.KinesisEventResponse
+
+	if failed < len(event.Records) {
+		h.log.Error(fmt.Sprintf("Failed to process %d of %d real-time log records", len(event.Records)-failed, len(event.Records)))
+		response.BatchItemFailures = []events.KinesisBatchItemFailure{
+			{ItemIdentifier: event.Records[failed].Kinesis.SequenceNumber},
+		}
+	}
+
+	return response, nil
+}
+
+// processKinesisRecord processes a real-time log record, creating the destination of its log group if it is the first.
+func (h *EventHandler) processKinesisRecord(ctx context.Context, record events.KinesisEventRecord, destinations map[string]*destination) error {
+	logGroup := h.realtimeLogGroup(record.Kinesis.Data)
+
+	dest, ok := destinations[logGroup]
+	if !ok {
+		// Real-time logs have no object, so quarantined lines are written to an object named after the first record.
+		quarantineKey := fmt.Sprintf("%s%s/%s.ndjson", h.quarantinePrefix, strings.TrimPrefix(logGroup, "/"), record.Kinesis.SequenceNumber)
+
+		var err error
+
+		dest, err = h.newDestination(ctx, logGroup, "", quarantineKey)
+		if err != nil {
+			return err
+		}
+
+		destinations[logGroup] = dest
+	}
+
+	options := h.options
+	// Real-time logs have no object, fall back to when the record arrived.
+	options.LastModified = record.Kinesis.ApproximateArrivalTimestamp.UTC()
+	options = dest.apply(options)
+	// Quarantined lines are identified by the stream and sequence number of their record.
+	options.Quarantine = dest.quarantineFunc(ctx, record.EventSourceArn, record.Kinesis.SequenceNumber)
+
+	return processor.ProcessRealtime(record.Kinesis.Data, options, dest.push(ctx))
+}
+
+// realtimeLogGroup returns the log group of a real-time log record, using the host header sent by the viewer or else
+// the CloudFront domain name. Records which can't be parsed are pushed to the real-time log group.
+func (h *EventHandler) realtimeLogGroup(data []byte) string {
+	if len(h.realtimeGroups) == 0 {
+		return h.realtimeGroup
+	}
+
+	line, _, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+
+	record, err := format.NewCloudFrontRealtime(h.options.Fields).NewParser().ParseLine(line)
+	if err != nil {
+		return h.realtimeGroup
 	}
-	h.log.Info(fmt.Sprintf("Fetched %s from %s from %s", utils.ByteCountBinary(n), key, bucket))
 
+	for _, host := range []string{record.HostHeader, record.Host} {
+		if logGroup, ok := h.realtimeGroups[host]; ok && host != "" {
+			return logGroup
+		}
+	}
+
+	return h.realtimeGroup
+}
+
+// destination of the events processed from a source of logs.
+type destination struct {
+	logs       *pusher.BatchLogPusher
+	quarantine *quarantine.Counter
+	summary    *enrich.POPSummary
+	summaries  *pusher.BatchLogPusher
+	redactor   *redact.Redactor
+	anonymiser *redact.Anonymiser
+	filter     *filter.Filter
+	sampler    *filter.Sampler
+	projected  struct {
+		before int64
+		after  int64
+	}
+}
+
+// newDestination creates the log group and streams which events will be pushed to.
+func (h *EventHandler) newDestination(ctx context.Context, logGroup, key, quarantineKey string) (*destination, error) {
 	h.log.Info("Creating log pusher")
-	logGroup := parser.GetLogGroupName(key)
 	logPusher := pusher.NewBatchLogPusher(ctx, h.log, h.cwLogsClient, logGroup, LogStreamName, h.batchSize)
 
 	h.log.Info("Creating log group")
 	if err := logPusher.CreateLogGroup(ctx, logGroup); err != nil {
-		return err
+		return nil, err
 	}
 
 	h.log.Info("Creating log stream")
 	if err := logPusher.CreateLogStream(ctx, logGroup, LogStreamName); err != nil {
-		return err
+		return nil, err
 	}
 
-	h.log.Info("Processing logs")
-	err = processor.ProcessLines(gzipBuff.Bytes(), func(event types.InputLogEvent) error {
-		err = logPusher.Add(ctx, event)
-		if err != nil {
-			return err
+	dest := &destination{
+		logs: logPusher,
+	}
+
+	if h.filters != nil {
+		dest.filter = h.filters.New()
+	}
+
+	if h.sampling != nil {
+		dest.sampler = h.sampling.New()
+	}
+
+	if h.redaction != nil {
+		// Redaction rules are scoped to the log group and the key of the object.
+		dest.redactor = h.redaction.For(logGroup, key)
+	}
+
+	anonymiser, err := h.newAnonymiser(logGroup)
+	if err != nil {
+		return nil, err
+	}
+	dest.anonymiser = anonymiser
+
+	if h.popSummary {
+		h.log.Info("Creating summary log stream")
+		dest.summary = enrich.NewPOPSummary(h.pops)
+		dest.summaries = pusher.NewBatchLogPusher(ctx, h.log, h.cwLogsClient, logGroup, SummaryStreamName, h.batchSize)
+		if err := dest.summaries.CreateLogStream(ctx, logGroup, SummaryStreamName); err != nil {
+			return nil, err
 		}
+	}
+
+	if h.options.Unparseable != processor.UnparseableQuarantine {
+		return dest, nil
+	}
+
+	switch h.quarantine {
+	case config.QuarantineS3:
+		dest.quarantine = quarantine.NewCounter(quarantine.NewS3Destination(h.s3Client, h.quarantineBucket, quarantineKey))
+	default:
+		h.log.Info("Creating quarantine log stream")
+		quarantinePusher := pusher.NewBatchLogPusher(ctx, h.log, h.cwLogsClient, logGroup, QuarantineStreamName, h.batchSize)
+		if err := quarantinePusher.CreateLogStream(ctx, logGroup, QuarantineStreamName); err != nil {
+			return nil, err
+		}
+		dest.quarantine = quarantine.NewCounter(quarantine.NewStreamDestination(quarantinePusher))
+	}
+
+	return dest, nil
+}
+
+// push returns a function which adds events to the log stream.
+func (d *destination) push(ctx context.Context) func(event cwtypes.InputLogEvent) error {
+	return func(event cwtypes.InputLogEvent) error {
+		return d.logs.Add(ctx, event)
+	}
+}
+
+// apply the per-destination filter, summary, redactors and counters to the options.
+func (d *destination) apply(options processor.Options) processor.Options {
+	options.Filters = d.filters()
+	options.Observers = d.observers()
+	options.Redactors = d.redactors()
+	options.Projected = d.projectedFunc(options.Projection)
+	return options
+}
+
+// observers returns the observers of the destination, summarising the events which are kept by the filters.
+func (d *destination) observers() []processor.Observer {
+	if d.summary == nil {
 		return nil
-	})
-	if err != nil {
-		return err
 	}
-	err = logPusher.Flush(ctx)
+
+	return []processor.Observer{d.summary}
+}
+
+// filters returns the filters of the destination, sampling the events which are kept by the filter rules.
+func (d *destination) filters() []processor.Filter {
+	var filters []processor.Filter
+
+	if d.filter != nil {
+		filters = append(filters, d.filter)
+	}
+
+	if d.sampler != nil {
+		filters = append(filters, d.sampler)
+	}
+
+	return filters
+}
+
+// newAnonymiser creates an anonymiser for the client IPs of the log group, or nil if they are kept.
+func (h *EventHandler) newAnonymiser(logGroup string) (*redact.Anonymiser, error) {
+	mode := h.anonymisation
+	if override, ok := h.anonymisationFor[logGroup]; ok {
+		mode = override
+	}
+
+	if mode == redact.ModeNone {
+		return nil, nil
+	}
+
+	return redact.NewAnonymiser(mode, h.anonymisationKey)
+}
+
+// redactors returns the redactors of the destination, anonymising client IPs after the redaction rules.
+func (d *destination) redactors() []processor.Redactor {
+	var redactors []processor.Redactor
+
+	if d.redactor != nil {
+		redactors = append(redactors, d.redactor)
+	}
+
+	if d.anonymiser != nil {
+		redactors = append(redactors, d.anonymiser)
+	}
+
+	return redactors
+}
+
+// projectedFunc returns a function which counts the size of messages before and after the projection was applied.
+func (d *destination) projectedFunc(projection processor.Projection) func(before, after int) {
+	if !projection.Enabled() {
+		return nil
+	}
+
+	return func(before, after int) {
+		d.projected.before += int64(before)
+		d.projected.after += int64(after)
+	}
+}
+
+// quarantineFunc returns a function which quarantines lines read from the object.
+func (d *destination) quarantineFunc(ctx context.Context, bucket, key string) func(line string, number int, reason error) error {
+	if d.quarantine == nil {
+		return nil
+	}
+
+	return func(line string, number int, reason error) error {
+		return d.quarantine.Add(ctx, quarantine.NewEntry(bucket, key, number, line, reason))
+	}
+}
+
+// flush the events of the destination and report what was quarantined.
+func (h *EventHandler) flush(ctx context.Context, dest *destination, source string) error {
+	err := dest.logs.Flush(ctx)
 	if err != nil {
 		return err
 	}
 
+	if dest.summary != nil {
+		err = h.flushSummary(ctx, dest)
+		if err != nil {
+			return err
+		}
+	}
+
+	if h.options.Projection.Enabled() {
+		saved := dest.projected.before - dest.projected.after
+		h.log.Info(fmt.Sprintf("Projection saved %s of %s from %s", utils.ByteCountBinary(saved), utils.ByteCountBinary(dest.projected.before), source), "source", source, "bytes_before", dest.projected.before, "bytes_after", dest.projected.after, "bytes_saved", saved)
+	}
+
+	if dest.filter != nil {
+		h.log.Info(fmt.Sprintf("Filtered %d events from %s", dest.filter.Dropped(), source), "source", source, "dropped", dest.filter.Dropped(), "rules", dest.filter.Counts())
+	}
+
+	if dest.sampler != nil {
+		h.log.Info(fmt.Sprintf("Sampled out %d events from %s", dest.sampler.Dropped(), source), "source", source, "sampled_out", dest.sampler.Dropped(), "rules", dest.sampler.Counts())
+	}
+
+	if dest.redactor != nil {
+		h.log.Info(fmt.Sprintf("Redacted %d values from %s", dest.redactor.Total(), source), "source", source, "redacted", dest.redactor.Total(), "rules", dest.redactor.Counts())
+	}
+
+	if h.geoIP != nil && h.geoIP.Failed() > 0 {
+		// The databases are shared by every object, so this is the count since they were loaded.
+		h.log.Warn(fmt.Sprintf("%d GeoIP lookups have failed since the databases were loaded", h.geoIP.Failed()), "failed", h.geoIP.Failed())
+	}
+
+	if dest.quarantine != nil {
+		err = dest.quarantine.Flush(ctx)
+		if err != nil {
+			return err
+		}
+
+		h.log.Info(fmt.Sprintf("Quarantined %d lines from %s", dest.quarantine.Total(), source), "source", source, "quarantined", dest.quarantine.Total(), "reasons", dest.quarantine.Counts())
+	}
+
 	h.log.Info("Processing complete")
 
 	return nil
 }
+
+// flushSummary pushes the summary of each edge location, timestamped with the last request it served.
+func (h *EventHandler) flushSummary(ctx context.Context, dest *destination) error {
+	stats := dest.summary.Stats()
+
+	for _, pop := range stats {
+		message, err := pop.JSON()
+		if err != nil {
+			return fmt.Errorf("failed to format summary of %s: %w", pop.POP, err)
+		}
+
+		err = dest.summaries.Add(ctx, cwtypes.InputLogEvent{
+			Message:   aws.String(message),
+			Timestamp: aws.Int64(pop.End.UnixMilli()),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	h.log.Info(fmt.Sprintf("Summarised %d edge locations", len(stats)))
+
+	return dest.summaries.Flush(ctx)
+}
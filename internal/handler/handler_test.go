@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/format"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/ledger"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/pusher/mock"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/redact"
+)
+
+// mockS3 returns an object with the ETag, unless its key is one which fails.
+type mockS3 struct {
+	etag    string
+	failing []string
+}
+
+// GetObject implements the interface.
+func (m mockS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if slices.Contains(m.failing, aws.ToString(params.Key)) {
+		return nil, fmt.Errorf("access denied")
+	}
+
+	return &s3.GetObjectOutput{
+		Body: io.NopCloser(strings.NewReader("#Version: 1.0\n")),
+		ETag: aws.String(m.etag),
+	}, nil
+}
+
+// PutObject implements the interface.
+func (m mockS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestHandleEvent_Ledger(t *testing.T) {
+	ctx := context.Background()
+	objectLedger := ledger.NewMemory()
+
+	h := &EventHandler{
+		log:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		s3Client:      mockS3{etag: `"d41d8cd98f00b204e9800998ecf8427e"`},
+		ledger:        objectLedger,
+		leaseDuration: time.Minute,
+	}
+
+	var record events.S3EventRecord
+	record.S3.Bucket.Name = "my-logs"
+	record.S3.Object.Key = "dev/E38J4Y0L8GXH9D.2024-06-25-10.d51ccc94.gz"
+
+	object := ledger.NewObject("my-logs", "dev/E38J4Y0L8GXH9D.2024-06-25-10.d51ccc94.gz", "d41d8cd98f00b204e9800998ecf8427e")
+
+	// Notifications without the ETag fall back to the ETag of the object. Objects being ingested by another invocation
+	// are retried later.
+	lease, err := objectLedger.Acquire(ctx, object, time.Minute)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, h.HandleEvent(ctx, record), ledger.ErrLeased)
+
+	// Objects which have been ingested are skipped.
+	assert.NoError(t, objectLedger.Complete(ctx, lease))
+	assert.NoError(t, h.HandleEvent(ctx, record))
+	assert.Equal(t, ledger.StatusComplete, objectLedger.Status(object))
+}
+
+func TestHandleEvent_LedgerETag(t *testing.T) {
+	ctx := context.Background()
+	objectLedger := ledger.NewMemory()
+
+	failing := "dev/E38J4Y0L8GXH9D.2024-06-25-11.d51ccc94.gz"
+
+	h := &EventHandler{
+		log:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		s3Client:      mockS3{failing: []string{failing}},
+		cwLogsClient:  mock.NewCloudwatchLogs(),
+		batchSize:     10,
+		ledger:        objectLedger,
+		leaseDuration: time.Minute,
+		anonymisation: redact.ModeNone,
+	}
+
+	var record events.S3EventRecord
+	record.S3.Bucket.Name = "my-logs"
+	record.S3.Object.Key = "dev/E38J4Y0L8GXH9D.2024-06-25-10.d51ccc94.gz"
+	record.S3.Object.ETag = "d41d8cd98f00b204e9800998ecf8427e"
+
+	// Objects which are ingested are completed.
+	object := ledger.NewObject("my-logs", record.S3.Object.Key, record.S3.Object.ETag)
+	assert.NoError(t, h.HandleEvent(ctx, record))
+	assert.Equal(t, ledger.StatusComplete, objectLedger.Status(object))
+
+	// Objects which fail are released, so they can be retried.
+	record.S3.Object.Key = failing
+	object = ledger.NewObject("my-logs", record.S3.Object.Key, record.S3.Object.ETag)
+	assert.Error(t, h.HandleEvent(ctx, record))
+	assert.Equal(t, ledger.StatusFailed, objectLedger.Status(object))
+
+	// The lease is acquired using the ETag of the notification, so objects which have been ingested aren't fetched.
+	lease, err := objectLedger.Acquire(ctx, object, time.Minute)
+	assert.NoError(t, err)
+	assert.NoError(t, objectLedger.Complete(ctx, lease))
+	assert.NoError(t, h.HandleEvent(ctx, record))
+}
+
+func TestPartitionLayout(t *testing.T) {
+	h := &EventHandler{}
+	assert.Equal(t, "", h.partitionLayout(nil))
+	assert.Equal(t, "", h.partitionLayout(format.CloudFront{}))
+	assert.Equal(t, "{yyyy}/{MM}/{dd}/{HH}/{mm}", h.partitionLayout(format.WAF{}))
+
+	// Objects of each format detected by one function have their own date directories removed.
+	key := "skpr/my-cluster/my-project/dev/AWSLogs/123456789012/WAFLogs/cloudfront/my-web-acl/2024/06/25/10/40/123456789012_waflogs_cloudfront_my-web-acl_20240625T1040Z_a1b2c3d4.log.gz"
+	assert.Equal(t, "/skpr/my-cluster/my-project/dev/AWSLogs/123456789012/WAFLogs/cloudfront/my-web-acl", parser.GetPartitionedLogGroupName(key, h.partitionLayout(format.WAF{}), false))
+
+	key = "skpr/my-cluster/my-project/dev/AWSLogs/123456789012/elasticloadbalancing/ap-southeast-2/2024/06/25/123456789012_elasticloadbalancing_ap-southeast-2_app.my-alb.50dc6c495c0c9188_20240625T1040Z_192.0.2.1_a1b2c3d4.log.gz"
+	assert.Equal(t, "/skpr/my-cluster/my-project/dev/AWSLogs/123456789012/elasticloadbalancing/ap-southeast-2", parser.GetPartitionedLogGroupName(key, h.partitionLayout(format.ALB{}), false))
+
+	// The configured layout overrides the default of the format.
+	h.layout = "AWSLogs/{AccountId}/WAFLogs/{Region}/{WebACL}/{yyyy}/{MM}/{dd}/{HH}/{mm}"
+	assert.Equal(t, h.layout, h.partitionLayout(format.WAF{}))
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/processor"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/pusher/mock"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/redact"
+)
+
+// recordingLogs records the messages pushed to each log group.
+type recordingLogs struct {
+	*mock.CloudwatchLogs
+	lock     sync.Mutex
+	messages map[string][]string
+}
+
+// PutLogEvents implements the interface.
+func (l *recordingLogs) PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(options *cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, event := range params.LogEvents {
+		l.messages[aws.ToString(params.LogGroupName)] = append(l.messages[aws.ToString(params.LogGroupName)], aws.ToString(event.Message))
+	}
+
+	return &cloudwatchlogs.PutLogEventsOutput{}, nil
+}
+
+// statusEnricher fails to enrich records with the status.
+type statusEnricher int64
+
+// Enrich implements the interface.
+func (e statusEnricher) Enrich(record *parser.AccessLogRecord) error {
+	if *record.Status == int64(e) {
+		return fmt.Errorf("unable to enrich %d", e)
+	}
+
+	return nil
+}
+
+// kinesisEvent returns an event with a record for each line, numbered from 1.
+func kinesisEvent(lines ...string) events.KinesisEvent {
+	var event events.KinesisEvent
+
+	for i, line := range lines {
+		var record events.KinesisEventRecord
+		record.EventID = fmt.Sprintf("shardId-000000000000:%d", i+1)
+		record.Kinesis.SequenceNumber = fmt.Sprint(i + 1)
+		record.Kinesis.Data = []byte(line + "\n")
+		event.Records = append(event.Records, record)
+	}
+
+	return event
+}
+
+func TestHandleKinesisEvent(t *testing.T) {
+	logs := &recordingLogs{CloudwatchLogs: mock.NewCloudwatchLogs(), messages: make(map[string][]string)}
+
+	h := &EventHandler{
+		log:            slog.New(slog.NewTextHandler(io.Discard, nil)),
+		cwLogsClient:   logs,
+		batchSize:      10,
+		realtimeGroup:  "/cloudfront/realtime",
+		realtimeGroups: map[string]string{"www.example.com": "/cloudfront/example", "d111111abcdef8.cloudfront.net": "/cloudfront/distribution"},
+		anonymisation:  redact.ModeNone,
+		options: processor.Options{
+			Fields: []string{"timestamp", "cs-host", "x-host-header", "sc-status"},
+		},
+	}
+
+	response, err := h.HandleKinesisEvent(context.Background(), kinesisEvent(
+		"1591438392.123	d111111abcdef8.cloudfront.net	www.example.com	200",
+		"1591438393.123	d111111abcdef8.cloudfront.net	other.example.com	201",
+		"1591438394.123	d222222abcdef8.cloudfront.net	other.example.com	202",
+	))
+	assert.NoError(t, err)
+	assert.Empty(t, response.BatchItemFailures)
+
+	// Records are pushed to the log group of the host header, then of the CloudFront domain name.
+	assert.Equal(t, map[string][]string{
+		"/cloudfront/example":      {"d111111abcdef8.cloudfront.net	www.example.com	200"},
+		"/cloudfront/distribution": {"d111111abcdef8.cloudfront.net	other.example.com	201"},
+		"/cloudfront/realtime":     {"d222222abcdef8.cloudfront.net	other.example.com	202"},
+	}, logs.messages)
+}
+
+func TestHandleKinesisEvent_Failed(t *testing.T) {
+	logs := &recordingLogs{CloudwatchLogs: mock.NewCloudwatchLogs(), messages: make(map[string][]string)}
+
+	h := &EventHandler{
+		log:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		cwLogsClient:  logs,
+		batchSize:     10,
+		realtimeGroup: "/cloudfront/realtime",
+		anonymisation: redact.ModeNone,
+		options: processor.Options{
+			Fields:    []string{"timestamp", "sc-status"},
+			Enrichers: []processor.Enricher{statusEnricher(500)},
+		},
+	}
+
+	response, err := h.HandleKinesisEvent(context.Background(), kinesisEvent(
+		"1591438392.123	200",
+		"1591438393.123	500",
+		"1591438394.123	200",
+	))
+	assert.NoError(t, err)
+
+	// The records before the one which failed are pushed, and it is retried along with the records after it.
+	assert.Equal(t, []events.KinesisBatchItemFailure{{ItemIdentifier: "2"}}, response.BatchItemFailures)
+	assert.Equal(t, map[string][]string{"/cloudfront/realtime": {"200"}}, logs.messages)
+}
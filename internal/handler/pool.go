@@ -0,0 +1,30 @@
+package handler
+
+import "sync"
+
+// forEach calls fn with each index up to n, running up to concurrency calls at once. The errors are returned in order
+// of their index, with nil for the calls which succeeded.
+func forEach(n, concurrency int, fn func(i int) error) []error {
+	errs := make([]error, n)
+	workers := make(chan struct{}, max(concurrency, 1))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		workers <- struct{}{}
+		wg.Add(1)
+
+		go func(i int) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+
+			errs[i] = fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+
+	return errs
+}
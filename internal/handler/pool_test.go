@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForEach(t *testing.T) {
+	var running, peak atomic.Int32
+
+	errs := forEach(10, 3, func(i int) error {
+		current := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			previous := peak.Load()
+			if current <= previous || peak.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+
+		if i%4 == 0 {
+			return errors.New("failed")
+		}
+
+		return nil
+	})
+
+	assert.Equal(t, int32(3), peak.Load())
+	assert.Len(t, errs, 10)
+
+	// Errors don't stop the other calls, and are kept in order.
+	for i, err := range errs {
+		if i%4 == 0 {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+
+	assert.Empty(t, forEach(0, 3, func(i int) error { return nil }))
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/notification"
+)
+
+// HandleSQSEvent handles s3 notifications delivered by SQS, returning the messages which failed so only they are
+// retried. A message fails if it can't be parsed or if any of its objects fail to be processed.
+func (h *EventHandler) HandleSQSEvent(ctx context.Context, event events.SQSEvent) events.SQSEventResponse {
+	var (
+		records []events.S3EventRecord
+		// messages are the index of the message of each record.
+		messages []int
+		failed   = make([]bool, len(event.Records))
+	)
+
+	for i, message := range event.Records {
+		found, err := notification.Records([]byte(message.Body))
+		if err != nil {
+			// The records which could be parsed are still processed, the message is retried for the rest.
+			h.log.Error(fmt.Sprintf("Failed to parse message %s", message.MessageId), "message_id", message.MessageId, "error", err)
+			failed[i] = true
+		}
+
+		for _, record := range found {
+			records = append(records, record)
+			messages = append(messages, i)
+		}
+	}
+
+	for i, err := range h.handleRecords(ctx, records) {
+		if err != nil {
+			failed[messages[i]] = true
+		}
+	}
+
+	var response events.SQSEventResponse
+
+	for i, message := range event.Records {
+		if failed[i] {
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{
+				ItemIdentifier: message.MessageId,
+			})
+		}
+	}
+
+	if len(response.BatchItemFailures) > 0 {
+		h.log.Error(fmt.Sprintf("Failed to process %d of %d messages", len(response.BatchItemFailures), len(event.Records)))
+	}
+
+	return response
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/pusher/mock"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/redact"
+)
+
+func TestHandleSQSEvent(t *testing.T) {
+	h := &EventHandler{
+		log:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		concurrency: 2,
+	}
+
+	response := h.HandleSQSEvent(context.Background(), events.SQSEvent{
+		Records: []events.SQSMessage{
+			{MessageId: "test", Body: `{"Service":"Amazon S3","Event":"s3:TestEvent","Bucket":"my-logs"}`},
+			{MessageId: "poison", Body: `not json`},
+			{MessageId: "unsupported", Body: `{"hello":"world"}`},
+			{MessageId: "empty", Body: `{"Records":[]}`},
+		},
+	})
+
+	// Only the messages which failed are retried.
+	assert.Equal(t, []events.SQSBatchItemFailure{
+		{ItemIdentifier: "poison"},
+		{ItemIdentifier: "unsupported"},
+	}, response.BatchItemFailures)
+}
+
+func TestHandleSQSEvent_FailedObjects(t *testing.T) {
+	h := &EventHandler{
+		log:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		s3Client:      mockS3{failing: []string{"dev/E38J4Y0L8GXH9D.2024-06-25-11.d51ccc94.gz"}},
+		cwLogsClient:  mock.NewCloudwatchLogs(),
+		batchSize:     10,
+		concurrency:   2,
+		anonymisation: redact.ModeNone,
+	}
+
+	response := h.HandleSQSEvent(context.Background(), events.SQSEvent{
+		Records: []events.SQSMessage{
+			{MessageId: "partial", Body: s3Notification(t, "dev/E38J4Y0L8GXH9D.2024-06-25-10.d51ccc94.gz", "dev/E38J4Y0L8GXH9D.2024-06-25-11.d51ccc94.gz")},
+			{MessageId: "processed", Body: s3Notification(t, "dev/E38J4Y0L8GXH9D.2024-06-25-12.d51ccc94.gz")},
+		},
+	})
+
+	// A message is retried if any of its objects failed.
+	assert.Equal(t, []events.SQSBatchItemFailure{
+		{ItemIdentifier: "partial"},
+	}, response.BatchItemFailures)
+}
+
+// s3Notification returns an s3 notification of the objects in the my-logs bucket.
+func s3Notification(t *testing.T, keys ...string) string {
+	var event events.S3Event
+
+	for _, key := range keys {
+		var record events.S3EventRecord
+		record.EventSource = "aws:s3"
+		record.S3.Bucket.Name = "my-logs"
+		record.S3.Object.Key = key
+		event.Records = append(event.Records, record)
+	}
+
+	body, err := json.Marshal(event)
+	assert.NoError(t, err)
+
+	return string(body)
+}
@@ -0,0 +1,149 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Attributes of the items in a DynamoDB ledger table.
+const (
+	// AttributeID is the partition key of the table, made up of the bucket, key and ETag.
+	AttributeID       = "id"
+	attributeBucket   = "bucket"
+	attributeKey      = "key"
+	attributeETag     = "etag"
+	attributeStatus   = "status"
+	attributeToken    = "lease_token"
+	attributeExpires  = "lease_expires"
+	attributeModified = "updated_at"
+)
+
+// DynamoDBInterface provides an interface for the DynamoDB client.
+type DynamoDBInterface interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+}
+
+// DynamoDB is a ledger stored in a DynamoDB table with a string partition key named id.
+type DynamoDB struct {
+	client DynamoDBInterface
+	table  string
+	// now returns the current time.
+	now func() time.Time
+}
+
+// NewDynamoDB creates a ledger stored in the table.
+func NewDynamoDB(client DynamoDBInterface, table string) *DynamoDB {
+	return &DynamoDB{
+		client: client,
+		table:  table,
+		now:    time.Now,
+	}
+}
+
+// Acquire implements the interface.
+func (d *DynamoDB) Acquire(ctx context.Context, object Object, duration time.Duration) (Lease, error) {
+	token, err := newToken()
+	if err != nil {
+		return Lease{}, err
+	}
+
+	now := d.now()
+
+	lease := Lease{
+		Object:  object,
+		Token:   token,
+		Expires: now.Add(duration),
+	}
+
+	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.table),
+		Item: map[string]types.AttributeValue{
+			AttributeID:       &types.AttributeValueMemberS{Value: object.ID()},
+			attributeBucket:   &types.AttributeValueMemberS{Value: object.Bucket},
+			attributeKey:      &types.AttributeValueMemberS{Value: object.Key},
+			attributeETag:     &types.AttributeValueMemberS{Value: object.ETag},
+			attributeStatus:   &types.AttributeValueMemberS{Value: string(StatusInProgress)},
+			attributeToken:    &types.AttributeValueMemberS{Value: lease.Token},
+			attributeExpires:  &types.AttributeValueMemberN{Value: strconv.FormatInt(lease.Expires.UnixMilli(), 10)},
+			attributeModified: &types.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+		},
+		// New objects, objects which failed and objects whose lease has expired can be leased.
+		ConditionExpression: aws.String("attribute_not_exists(#id) OR #status = :failed OR (#status = :in_progress AND #expires < :now)"),
+		ExpressionAttributeNames: map[string]string{
+			"#id":      AttributeID,
+			"#status":  attributeStatus,
+			"#expires": attributeExpires,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":failed":      &types.AttributeValueMemberS{Value: string(StatusFailed)},
+			":in_progress": &types.AttributeValueMemberS{Value: string(StatusInProgress)},
+			":now":         &types.AttributeValueMemberN{Value: strconv.FormatInt(now.UnixMilli(), 10)},
+		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if !errors.As(err, &conditionErr) {
+			return Lease{}, fmt.Errorf("failed to acquire lease on %s: %w", object.ID(), err)
+		}
+
+		if status, ok := conditionErr.Item[attributeStatus].(*types.AttributeValueMemberS); ok && status.Value == string(StatusComplete) {
+			return Lease{}, ErrComplete
+		}
+
+		return Lease{}, ErrLeased
+	}
+
+	return lease, nil
+}
+
+// Complete implements the interface.
+func (d *DynamoDB) Complete(ctx context.Context, lease Lease) error {
+	return d.finish(ctx, lease, StatusComplete)
+}
+
+// Release implements the interface.
+func (d *DynamoDB) Release(ctx context.Context, lease Lease) error {
+	return d.finish(ctx, lease, StatusFailed)
+}
+
+// finish the lease, setting the status of its object.
+func (d *DynamoDB) finish(ctx context.Context, lease Lease, status Status) error {
+	_, err := d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(d.table),
+		Key: map[string]types.AttributeValue{
+			AttributeID: &types.AttributeValueMemberS{Value: lease.Object.ID()},
+		},
+		UpdateExpression:    aws.String("SET #status = :status, #modified = :now REMOVE #token, #expires"),
+		ConditionExpression: aws.String("#token = :token"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":   attributeStatus,
+			"#modified": attributeModified,
+			"#token":    attributeToken,
+			"#expires":  attributeExpires,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status": &types.AttributeValueMemberS{Value: string(status)},
+			":now":    &types.AttributeValueMemberS{Value: d.now().UTC().Format(time.RFC3339)},
+			":token":  &types.AttributeValueMemberS{Value: lease.Token},
+		},
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrLeaseLost
+		}
+
+		return fmt.Errorf("failed to mark %s as %s: %w", lease.Object.ID(), status, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockDynamoDB records the requests made to it and returns the error.
+type mockDynamoDB struct {
+	puts    []*dynamodb.PutItemInput
+	updates []*dynamodb.UpdateItemInput
+	err     error
+}
+
+// PutItem implements the interface.
+func (m *mockDynamoDB) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	m.puts = append(m.puts, params)
+	return &dynamodb.PutItemOutput{}, m.err
+}
+
+// UpdateItem implements the interface.
+func (m *mockDynamoDB) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	m.updates = append(m.updates, params)
+	return &dynamodb.UpdateItemOutput{}, m.err
+}
+
+func TestDynamoDB(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 25, 10, 40, 0, 0, time.UTC)
+	object := NewObject("my-logs", "dev/E38J4Y0L8GXH9D.2024-06-25-10.d51ccc94.gz", "d41d8cd98f00b204e9800998ecf8427e")
+
+	client := &mockDynamoDB{}
+	ledger := NewDynamoDB(client, "ingestion-ledger")
+	ledger.now = func() time.Time { return now }
+
+	lease, err := ledger.Acquire(ctx, object, time.Minute)
+	assert.NoError(t, err)
+
+	put := client.puts[0]
+	assert.Equal(t, "ingestion-ledger", aws.ToString(put.TableName))
+	assert.Equal(t, &types.AttributeValueMemberS{Value: object.ID()}, put.Item[AttributeID])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "in_progress"}, put.Item["status"])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: lease.Token}, put.Item["lease_token"])
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "1719312060000"}, put.Item["lease_expires"])
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "1719312000000"}, put.ExpressionAttributeValues[":now"])
+
+	assert.NoError(t, ledger.Complete(ctx, lease))
+
+	update := client.updates[0]
+	assert.Equal(t, map[string]types.AttributeValue{AttributeID: &types.AttributeValueMemberS{Value: object.ID()}}, update.Key)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "complete"}, update.ExpressionAttributeValues[":status"])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: lease.Token}, update.ExpressionAttributeValues[":token"])
+
+	// The status of the object decides why the lease couldn't be acquired.
+	client.err = &types.ConditionalCheckFailedException{
+		Item: map[string]types.AttributeValue{"status": &types.AttributeValueMemberS{Value: "complete"}},
+	}
+	_, err = ledger.Acquire(ctx, object, time.Minute)
+	assert.ErrorIs(t, err, ErrComplete)
+
+	client.err = &types.ConditionalCheckFailedException{
+		Item: map[string]types.AttributeValue{"status": &types.AttributeValueMemberS{Value: "in_progress"}},
+	}
+	_, err = ledger.Acquire(ctx, object, time.Minute)
+	assert.ErrorIs(t, err, ErrLeased)
+
+	assert.ErrorIs(t, ledger.Release(ctx, lease), ErrLeaseLost)
+
+	client.err = errors.New("throttled")
+	_, err = ledger.Acquire(ctx, object, time.Minute)
+	assert.ErrorContains(t, err, "failed to acquire lease on my-logs/dev/E38J4Y0L8GXH9D.2024-06-25-10.d51ccc94.gz@d41d8cd98f00b204e9800998ecf8427e: throttled")
+
+	err = ledger.Release(ctx, lease)
+	assert.ErrorContains(t, err, "as failed: throttled")
+}
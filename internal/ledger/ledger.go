@@ -0,0 +1,83 @@
+package ledger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Status of an object in the ledger.
+type Status string
+
+const (
+	// StatusInProgress is an object which is being ingested under a lease.
+	StatusInProgress Status = "in_progress"
+	// StatusComplete is an object which has been ingested.
+	StatusComplete Status = "complete"
+	// StatusFailed is an object which failed to be ingested, and can be retried.
+	StatusFailed Status = "failed"
+)
+
+var (
+	// ErrComplete is returned when acquiring a lease on an object which has already been ingested.
+	ErrComplete = errors.New("object has already been ingested")
+	// ErrLeased is returned when acquiring a lease on an object which is being ingested under another lease.
+	ErrLeased = errors.New("object is being ingested under another lease")
+	// ErrLeaseLost is returned when completing or releasing a lease which has been taken over after it expired.
+	ErrLeaseLost = errors.New("lease has been taken over")
+)
+
+// Object is a version of an s3 object, identified by its ETag.
+type Object struct {
+	Bucket string
+	Key    string
+	ETag   string
+}
+
+// NewObject creates an object, removing the quotes s3 puts around the ETag.
+func NewObject(bucket, key, etag string) Object {
+	return Object{
+		Bucket: bucket,
+		Key:    key,
+		ETag:   strings.Trim(etag, `"`),
+	}
+}
+
+// ID of the object in the ledger.
+func (o Object) ID() string {
+	return o.Bucket + "/" + o.Key + "@" + o.ETag
+}
+
+// Lease to ingest an object.
+type Lease struct {
+	Object Object
+	// Token identifies the holder of the lease.
+	Token string
+	// Expires is when another lease can be acquired on the object.
+	Expires time.Time
+}
+
+// Ledger records the ingestion of s3 objects, so each version of an object is only ingested once.
+type Ledger interface {
+	// Acquire a lease to ingest the object for the duration. ErrComplete is returned if the object has already been
+	// ingested, and ErrLeased if it is being ingested under another lease which hasn't expired.
+	Acquire(ctx context.Context, object Object, duration time.Duration) (Lease, error)
+	// Complete marks the object of the lease as ingested.
+	Complete(ctx context.Context, lease Lease) error
+	// Release marks the object of the lease as failed, so it can be retried straight away.
+	Release(ctx context.Context, lease Lease) error
+}
+
+// newToken creates a random token to identify the holder of a lease.
+func newToken() (string, error) {
+	token := make([]byte, 16)
+
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(token), nil
+}
@@ -0,0 +1,100 @@
+package ledger
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a ledger which is kept in memory, for tests and local development.
+type Memory struct {
+	entries map[Object]memoryEntry
+	lock    sync.Mutex
+	// now returns the current time.
+	now func() time.Time
+}
+
+// memoryEntry is the status of an object in a memory ledger.
+type memoryEntry struct {
+	status  Status
+	token   string
+	expires time.Time
+}
+
+// NewMemory creates an empty ledger which is kept in memory.
+func NewMemory() *Memory {
+	return &Memory{
+		entries: make(map[Object]memoryEntry),
+		now:     time.Now,
+	}
+}
+
+// Acquire implements the interface.
+func (m *Memory) Acquire(ctx context.Context, object Object, duration time.Duration) (Lease, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := m.now()
+
+	if entry, ok := m.entries[object]; ok {
+		if entry.status == StatusComplete {
+			return Lease{}, ErrComplete
+		}
+		if entry.status == StatusInProgress && now.Before(entry.expires) {
+			return Lease{}, ErrLeased
+		}
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return Lease{}, err
+	}
+
+	lease := Lease{
+		Object:  object,
+		Token:   token,
+		Expires: now.Add(duration),
+	}
+
+	m.entries[object] = memoryEntry{
+		status:  StatusInProgress,
+		token:   lease.Token,
+		expires: lease.Expires,
+	}
+
+	return lease, nil
+}
+
+// Complete implements the interface.
+func (m *Memory) Complete(ctx context.Context, lease Lease) error {
+	return m.finish(lease, StatusComplete)
+}
+
+// Release implements the interface.
+func (m *Memory) Release(ctx context.Context, lease Lease) error {
+	return m.finish(lease, StatusFailed)
+}
+
+// finish the lease, setting the status of its object.
+func (m *Memory) finish(lease Lease, status Status) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if entry, ok := m.entries[lease.Object]; !ok || entry.token != lease.Token {
+		return ErrLeaseLost
+	}
+
+	m.entries[lease.Object] = memoryEntry{
+		status: status,
+	}
+
+	return nil
+}
+
+// Status of the object, or an empty status if it isn't in the ledger.
+func (m *Memory) Status(object Object) Status {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.entries[object].status
+}
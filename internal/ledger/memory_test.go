@@ -0,0 +1,64 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewObject(t *testing.T) {
+	object := NewObject("my-logs", "dev/E38J4Y0L8GXH9D.2024-06-25-10.d51ccc94.gz", `"d41d8cd98f00b204e9800998ecf8427e"`)
+	assert.Equal(t, "d41d8cd98f00b204e9800998ecf8427e", object.ETag)
+	assert.Equal(t, "my-logs/dev/E38J4Y0L8GXH9D.2024-06-25-10.d51ccc94.gz@d41d8cd98f00b204e9800998ecf8427e", object.ID())
+}
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 25, 10, 40, 0, 0, time.UTC)
+
+	ledger := NewMemory()
+	ledger.now = func() time.Time { return now }
+
+	object := NewObject("my-logs", "dev/E38J4Y0L8GXH9D.2024-06-25-10.d51ccc94.gz", "d41d8cd98f00b204e9800998ecf8427e")
+
+	lease, err := ledger.Acquire(ctx, object, time.Minute)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, lease.Token)
+	assert.Equal(t, now.Add(time.Minute), lease.Expires)
+	assert.Equal(t, StatusInProgress, ledger.Status(object))
+
+	// The object is guarded by the lease until it expires.
+	_, err = ledger.Acquire(ctx, object, time.Minute)
+	assert.ErrorIs(t, err, ErrLeased)
+
+	now = now.Add(2 * time.Minute)
+
+	retry, err := ledger.Acquire(ctx, object, time.Minute)
+	assert.NoError(t, err)
+	assert.NotEqual(t, lease.Token, retry.Token)
+
+	// The expired lease can't finish the object.
+	assert.ErrorIs(t, ledger.Complete(ctx, lease), ErrLeaseLost)
+
+	// Objects which failed can be retried straight away.
+	assert.NoError(t, ledger.Release(ctx, retry))
+	assert.Equal(t, StatusFailed, ledger.Status(object))
+
+	lease, err = ledger.Acquire(ctx, object, time.Minute)
+	assert.NoError(t, err)
+
+	// Objects which are complete are skipped.
+	assert.NoError(t, ledger.Complete(ctx, lease))
+	assert.Equal(t, StatusComplete, ledger.Status(object))
+
+	_, err = ledger.Acquire(ctx, object, time.Minute)
+	assert.ErrorIs(t, err, ErrComplete)
+
+	// Other versions of the object are ingested.
+	_, err = ledger.Acquire(ctx, NewObject(object.Bucket, object.Key, "9e107d9d372bb6826bd81d3542a419d6"), time.Minute)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, ledger.Release(ctx, Lease{Object: NewObject("my-logs", "missing", "")}), ErrLeaseLost)
+}
@@ -0,0 +1,176 @@
+package notification
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	// SourceS3 is the event source of s3 event notification records.
+	SourceS3 = "aws:s3"
+	// SourceSNS is the event source of SNS records.
+	SourceSNS = "aws:sns"
+	// SourceSQS is the event source of SQS records.
+	SourceSQS = "aws:sqs"
+	// SourceEventBridge is the source of s3 events delivered by EventBridge.
+	SourceEventBridge = "aws.s3"
+	// DetailTypeObjectCreated is the detail type of EventBridge events for new objects.
+	DetailTypeObjectCreated = "Object Created"
+	// TypeNotification is the type of SNS messages delivered to SQS without raw message delivery.
+	TypeNotification = "Notification"
+	// EventTest is the event sent by s3 when a notification is configured.
+	EventTest = "s3:TestEvent"
+)
+
+// ErrUnsupported is returned for notifications which aren't s3 events or an envelope of them.
+var ErrUnsupported = errors.New("unsupported notification")
+
+// envelope has the fields used to tell the envelopes of s3 events apart. Field names are matched without regard to
+// case, so eg. Records matches both SNS and s3 records.
+type envelope struct {
+	// Records of SNS, SQS and s3 events.
+	Records []json.RawMessage `json:"Records"`
+	// Type and Message of SNS messages delivered to SQS.
+	Type    string  `json:"Type"`
+	Message *string `json:"Message"`
+	// Event of s3 test events.
+	Event string `json:"Event"`
+	// DetailType and Source of EventBridge events.
+	DetailType string `json:"detail-type"`
+	Source     string `json:"source"`
+}
+
+// record has the fields used to tell the records of SNS, SQS and s3 events apart.
+type record struct {
+	EventSource string `json:"eventSource"`
+	SNS         struct {
+		Message string `json:"Message"`
+	} `json:"Sns"`
+	Body string `json:"body"`
+}
+
+// eventBridgeDetail is the detail of an EventBridge s3 event.
+type eventBridgeDetail struct {
+	Bucket struct {
+		Name string `json:"name"`
+	} `json:"bucket"`
+	Object struct {
+		Key       string `json:"key"`
+		Size      int64  `json:"size"`
+		ETag      string `json:"etag"`
+		VersionID string `json:"version-id"`
+		Sequencer string `json:"sequencer"`
+	} `json:"object"`
+	Reason string `json:"reason"`
+}
+
+// Records returns the s3 event records of a notification, which is either an s3 event, an EventBridge s3 event, or
+// either of those wrapped by SNS, SQS or both. Object keys are URL-decoded. Test events and EventBridge events for
+// anything other than new objects have no records. The records which could be parsed are returned along with the
+// errors of those which couldn't.
+func Records(payload []byte) ([]events.S3EventRecord, error) {
+	var e envelope
+
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, fmt.Errorf("failed to parse notification: %w", err)
+	}
+
+	switch {
+	case e.Records != nil:
+		var (
+			records []events.S3EventRecord
+			errs    []error
+		)
+
+		for _, raw := range e.Records {
+			// Records which fail don't stop the others, so their objects can still be processed.
+			found, err := fromRecord(raw)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			records = append(records, found...)
+		}
+
+		return records, errors.Join(errs...)
+	case e.Source == SourceEventBridge:
+		return fromEventBridge(payload)
+	case e.Type == TypeNotification && e.Message != nil:
+		return Records([]byte(*e.Message))
+	case e.Event == EventTest:
+		return nil, nil
+	}
+
+	return nil, ErrUnsupported
+}
+
+// fromRecord returns the s3 event records of an SNS, SQS or s3 record.
+func fromRecord(raw json.RawMessage) ([]events.S3EventRecord, error) {
+	var r record
+
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse record: %w", err)
+	}
+
+	switch r.EventSource {
+	case SourceSNS:
+		return Records([]byte(r.SNS.Message))
+	case SourceSQS:
+		return Records([]byte(r.Body))
+	case SourceS3:
+		var s3Record events.S3EventRecord
+
+		if err := json.Unmarshal(raw, &s3Record); err != nil {
+			return nil, fmt.Errorf("failed to parse s3 record: %w", err)
+		}
+
+		// Keys are URL-encoded, with spaces encoded as +.
+		s3Record.S3.Object.Key = s3Record.S3.Object.URLDecodedKey
+
+		return []events.S3EventRecord{s3Record}, nil
+	}
+
+	return nil, fmt.Errorf("%w: records from %q", ErrUnsupported, r.EventSource)
+}
+
+// fromEventBridge returns the s3 event record of an EventBridge s3 event.
+func fromEventBridge(payload []byte) ([]events.S3EventRecord, error) {
+	var event events.EventBridgeEvent
+
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse EventBridge event: %w", err)
+	}
+
+	if event.DetailType != DetailTypeObjectCreated {
+		return nil, nil
+	}
+
+	var detail eventBridgeDetail
+
+	if err := json.Unmarshal(event.Detail, &detail); err != nil {
+		return nil, fmt.Errorf("failed to parse EventBridge event detail: %w", err)
+	}
+
+	record := events.S3EventRecord{
+		EventSource: SourceS3,
+		AWSRegion:   event.Region,
+		EventTime:   event.Time,
+		EventName:   "ObjectCreated:" + detail.Reason,
+	}
+
+	record.S3.Bucket.Name = detail.Bucket.Name
+	record.S3.Bucket.Arn = "arn:aws:s3:::" + detail.Bucket.Name
+	record.S3.Object = events.S3Object{
+		// Keys of EventBridge events are not URL-encoded.
+		Key:           detail.Object.Key,
+		URLDecodedKey: detail.Object.Key,
+		Size:          detail.Object.Size,
+		ETag:          detail.Object.ETag,
+		VersionID:     detail.Object.VersionID,
+		Sequencer:     detail.Object.Sequencer,
+	}
+
+	return []events.S3EventRecord{record}, nil
+}
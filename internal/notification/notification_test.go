@@ -0,0 +1,108 @@
+package notification
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testS3Event          = `{"Records":[{"eventVersion":"2.1","eventSource":"aws:s3","awsRegion":"ap-southeast-2","eventTime":"2024-06-25T10:40:00.000Z","eventName":"ObjectCreated:Put","s3":{"s3SchemaVersion":"1.0","bucket":{"name":"my-logs","arn":"arn:aws:s3:::my-logs"},"object":{"key":"skpr/dev/my+site/E38J4Y0L8GXH9D.2024-06-25-10.d51ccc94%2B1.gz","size":1024,"eTag":"d41d8cd98f00b204e9800998ecf8427e","sequencer":"0055AED6DCD90281E5"}}}]}`
+	testEventBridgeEvent = `{"version":"0","id":"17793124-05d4-b198-2fde-7ededc63b103","detail-type":"Object Created","source":"aws.s3","account":"123456789012","time":"2024-06-25T10:40:00Z","region":"ap-southeast-2","resources":["arn:aws:s3:::my-logs"],"detail":{"version":"0","bucket":{"name":"my-logs"},"object":{"key":"skpr/dev/my site/E38J4Y0L8GXH9D.2024-06-25-10.d51ccc94+1.gz","size":1024,"etag":"d41d8cd98f00b204e9800998ecf8427e","sequencer":"0055AED6DCD90281E5"},"request-id":"N4N7GDK58NMKJ12R","requester":"123456789012","reason":"PutObject"}}`
+	testKey              = "skpr/dev/my site/E38J4Y0L8GXH9D.2024-06-25-10.d51ccc94+1.gz"
+)
+
+// snsEvent wraps a message in an SNS event.
+func snsEvent(message string) string {
+	return `{"Records":[{"EventSource":"aws:sns","EventVersion":"1.0","Sns":{"Type":"Notification","MessageId":"95df01b4-ee98-5cb9-9903-4c221d41eb5e","Message":` + quote(message) + `}}]}`
+}
+
+// sqsEvent wraps a message in an SQS event.
+func sqsEvent(body string) string {
+	return `{"Records":[{"messageId":"059f36b4-87a3-44ab-83d2-661975830a7d","eventSource":"aws:sqs","body":` + quote(body) + `}]}`
+}
+
+// snsMessage wraps a message in the SNS notification delivered to SQS without raw message delivery.
+func snsMessage(message string) string {
+	return `{"Type":"Notification","MessageId":"95df01b4-ee98-5cb9-9903-4c221d41eb5e","Message":` + quote(message) + `}`
+}
+
+// quote a string as JSON.
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func TestRecords(t *testing.T) {
+	tests := map[string]string{
+		"s3":                     testS3Event,
+		"sns":                    snsEvent(testS3Event),
+		"sqs":                    sqsEvent(testS3Event),
+		"sns to sqs":             sqsEvent(snsMessage(testS3Event)),
+		"eventbridge":            testEventBridgeEvent,
+		"eventbridge sns":        snsEvent(testEventBridgeEvent),
+		"eventbridge sqs":        sqsEvent(testEventBridgeEvent),
+		"eventbridge sns to sqs": sqsEvent(snsMessage(testEventBridgeEvent)),
+	}
+
+	for name, payload := range tests {
+		records, err := Records([]byte(payload))
+		assert.NoError(t, err, name)
+
+		if assert.Len(t, records, 1, name) {
+			assert.Equal(t, SourceS3, records[0].EventSource, name)
+			assert.Equal(t, "my-logs", records[0].S3.Bucket.Name, name)
+			assert.Equal(t, testKey, records[0].S3.Object.Key, name)
+			assert.Equal(t, int64(1024), records[0].S3.Object.Size, name)
+			assert.Equal(t, "2024-06-25T10:40:00Z", records[0].EventTime.Format("2006-01-02T15:04:05Z07:00"), name)
+		}
+	}
+}
+
+func TestRecords_Skipped(t *testing.T) {
+	records, err := Records([]byte(`{"Service":"Amazon S3","Event":"s3:TestEvent","Time":"2024-06-25T10:40:00.000Z","Bucket":"my-logs"}`))
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	records, err = Records([]byte(`{"version":"0","detail-type":"Object Deleted","source":"aws.s3","detail":{"bucket":{"name":"my-logs"},"object":{"key":"a"}}}`))
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	records, err = Records([]byte(`{"Records":[]}`))
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestRecords_Errors(t *testing.T) {
+	_, err := Records([]byte(`{"hello":"world"}`))
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	_, err = Records([]byte(`{"Records":[{"eventSource":"aws:dynamodb"}]}`))
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	_, err = Records([]byte(`not json`))
+	assert.ErrorContains(t, err, "failed to parse notification")
+
+	_, err = Records([]byte(sqsEvent("not json")))
+	assert.ErrorContains(t, err, "failed to parse notification")
+
+	_, err = Records([]byte(`{"Records":[{"eventSource":"aws:s3","s3":{"object":{"key":"%zz"}}}]}`))
+	assert.ErrorContains(t, err, "failed to parse s3 record")
+}
+
+func TestRecords_Partial(t *testing.T) {
+	// Records which fail don't stop the others.
+	payload := `{"Records":[` +
+		`{"EventSource":"aws:sns","Sns":{"Message":"not json"}},` +
+		`{"EventSource":"aws:sns","Sns":{"Message":` + quote(testS3Event) + `}},` +
+		`{"eventSource":"aws:dynamodb"}]}`
+
+	records, err := Records([]byte(payload))
+	assert.ErrorContains(t, err, "failed to parse notification")
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, testKey, records[0].S3.Object.Key)
+	}
+}
@@ -0,0 +1,166 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+)
+
+// RawSuffix is appended to the key of a decoded field to name the field holding its raw value, eg. user_agent_raw.
+const RawSuffix = "_raw"
+
+// EncodedFields are the fields which CloudFront URL-encodes.
+var EncodedFields = []string{
+	"cs-uri-stem",
+	"cs(Referer)",
+	"cs(User-Agent)",
+	"cs-uri-query",
+	"cs(Cookie)",
+	"cs-referer",
+	"cs-user-agent",
+	"cs-cookie",
+}
+
+// Decode the URL-encoded fields of the record, keeping the raw values available via Raw.
+func (r *AccessLogRecord) Decode() error {
+	for _, name := range EncodedFields {
+		if _, decoded := r.raw[name]; decoded || !r.has(name) {
+			continue
+		}
+
+		raw := r.Get(name)
+
+		decoded := Unescape(raw)
+		if decoded == raw {
+			continue
+		}
+
+		if err := r.Set(name, decoded); err != nil {
+			return err
+		}
+
+		if r.raw == nil {
+			r.raw = make(map[string]string)
+		}
+
+		r.raw[name] = raw
+	}
+
+	return nil
+}
+
+// Raw returns the value of a field as it was before it was decoded.
+func (r *AccessLogRecord) Raw(name string) string {
+	if raw, ok := r.raw[name]; ok {
+		return raw
+	}
+
+	return r.Get(name)
+}
+
+// Decoded returns true if the field was changed when it was decoded.
+func (r *AccessLogRecord) Decoded(name string) bool {
+	_, ok := r.raw[name]
+	return ok
+}
+
+// SetRaw replaces the raw value of a decoded field, eg. once it has been redacted.
+func (r *AccessLogRecord) SetRaw(name, value string) {
+	if r.Decoded(name) {
+		r.raw[name] = value
+	}
+}
+
+// AddRawFields adds a field holding the raw value of each decoded field directly after it, named after its key with
+// the RawSuffix.
+func (r *AccessLogRecord) AddRawFields() {
+	for i := 0; i < len(r.fields); i++ {
+		name := r.fields[i]
+
+		raw, ok := r.raw[name]
+		if !ok {
+			continue
+		}
+
+		rawName := r.Key(name) + RawSuffix
+		if r.has(rawName) {
+			continue
+		}
+
+		// The field is appended, then moved next to the decoded field.
+		r.SetValue(rawName, raw)
+		r.fields = slices.Insert(r.fields[:len(r.fields)-1], i+1, rawName)
+		i++
+	}
+}
+
+// Unescape percent-encoded characters in a CloudFront field.
+//
+// CloudFront encodes a percent sign which is already part of an escape sequence a second time,
+// so a space sent as %20 is logged as %2520. Values containing these sequences are decoded twice.
+// Control characters are left encoded so values can't break the line based log formats.
+func Unescape(value string) string {
+	if !strings.Contains(value, "%") {
+		return value
+	}
+
+	doubleEncoded := hasDoubleEncoding(value)
+
+	value = unescape(value)
+
+	if doubleEncoded {
+		value = unescape(value)
+	}
+
+	return value
+}
+
+// hasDoubleEncoding returns true if the value contains an encoded percent sign followed by an escape sequence.
+func hasDoubleEncoding(value string) bool {
+	for i := strings.Index(value, "%25"); i >= 0; i = strings.Index(value, "%25") {
+		if len(value) >= i+5 && isHex(value[i+3]) && isHex(value[i+4]) {
+			return true
+		}
+		value = value[i+3:]
+	}
+
+	return false
+}
+
+// unescape a single level of percent-encoding.
+func unescape(value string) string {
+	var b strings.Builder
+
+	b.Grow(len(value))
+
+	for i := 0; i < len(value); i++ {
+		if value[i] == '%' && i+2 < len(value) && isHex(value[i+1]) && isHex(value[i+2]) {
+			c := unhex(value[i+1])<<4 | unhex(value[i+2])
+			if c >= 0x20 && c != 0x7f {
+				b.WriteByte(c)
+				i += 2
+				continue
+			}
+		}
+
+		b.WriteByte(value[i])
+	}
+
+	return b.String()
+}
+
+// isHex returns true if the character is a hexadecimal digit.
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// unhex returns the value of a hexadecimal digit.
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnescape(t *testing.T) {
+	assert.Equal(t, "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_5)", Unescape("Mozilla/5.0%20(Macintosh;%20Intel%20Mac%20OS%20X%2010_14_5)"))
+	// Double encoded values are decoded twice.
+	assert.Equal(t, "Mozilla/5.0 (X11; Linux)", Unescape("Mozilla/5.0%2520(X11;%2520Linux)"))
+	// A lone encoded percent sign is only decoded once.
+	assert.Equal(t, "discount=100%", Unescape("discount=100%25"))
+	// Malformed and control character sequences are left alone.
+	assert.Equal(t, "100%zz %09tab", Unescape("100%zz%20%09tab"))
+	assert.Equal(t, "-", Unescape("-"))
+}
+
+func TestAccessLogRecord_Decode(t *testing.T) {
+	record, err := ParseRecord(DefaultFields, testLine)
+	assert.NoError(t, err)
+
+	assert.NoError(t, record.Decode())
+	assert.Equal(t, "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_5) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/83.0.4103.97 Safari/537.36", record.UserAgent)
+	assert.Equal(t, "Mozilla/5.0%20(Macintosh;%20Intel%20Mac%20OS%20X%2010_14_5)%20AppleWebKit/537.36%20(KHTML,%20like%20Gecko)%20Chrome/83.0.4103.97%20Safari/537.36", record.Raw("cs(User-Agent)"))
+	// Fields which were not decoded return their current value.
+	assert.Equal(t, "/admin/people", record.Raw("cs-uri-stem"))
+	// Fields outside of the encoded set are untouched.
+	assert.Equal(t, "text/html;%20charset=UTF-8", record.ContentType)
+}
+
+func TestAccessLogRecord_AddRawFields(t *testing.T) {
+	record, err := ParseRecord([]string{"date", "time", "cs-uri-stem", "cs(User-Agent)", "sc-status"}, "2020-06-18	03:38:13	/search	curl/7.64.1%20(test)	200")
+	assert.NoError(t, err)
+
+	assert.NoError(t, record.Decode())
+	record.AddRawFields()
+
+	// Only decoded fields have a raw value, which follows the decoded value.
+	message, err := record.JSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"uri_stem":"/search","user_agent":"curl/7.64.1 (test)","user_agent_raw":"curl/7.64.1%20(test)","status":200}`, message)
+}
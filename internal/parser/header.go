@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"strings"
+)
+
+const (
+	// DirectiveVersion is the directive which declares the log file format version.
+	DirectiveVersion = "#Version:"
+	// DirectiveFields is the directive which declares the columns of each line.
+	DirectiveFields = "#Fields:"
+)
+
+// DefaultFields are the columns of a CloudFront standard log, used when a file has no #Fields directive.
+var DefaultFields = []string{
+	"date",
+	"time",
+	"x-edge-location",
+	"sc-bytes",
+	"c-ip",
+	"cs-method",
+	"cs(Host)",
+	"cs-uri-stem",
+	"sc-status",
+	"cs(Referer)",
+	"cs(User-Agent)",
+	"cs-uri-query",
+	"cs(Cookie)",
+	"x-edge-result-type",
+	"x-edge-request-id",
+	"x-host-header",
+	"cs-protocol",
+	"cs-bytes",
+	"time-taken",
+	"x-forwarded-for",
+	"ssl-protocol",
+	"ssl-cipher",
+	"x-edge-response-result-type",
+	"cs-protocol-version",
+	"fle-status",
+	"fle-encrypted-fields",
+	"c-port",
+	"time-to-first-byte",
+	"x-edge-detailed-result-type",
+	"sc-content-type",
+	"sc-content-len",
+	"sc-range-start",
+	"sc-range-end",
+}
+
+// Header describes the layout of a CloudFront log file as declared by its directives.
+type Header struct {
+	// Version of the log file format.
+	Version string
+	// Fields in the order they appear on each line.
+	Fields []string
+}
+
+// NewHeader creates a header with the default CloudFront fields.
+func NewHeader() *Header {
+	return &Header{
+		Fields: DefaultFields,
+	}
+}
+
+// ParseDirective updates the header from a directive line, returning false if the line is not a directive.
+func (h *Header) ParseDirective(line string) bool {
+	if !strings.HasPrefix(line, "#") {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(line, DirectiveVersion):
+		h.Version = strings.TrimSpace(strings.TrimPrefix(line, DirectiveVersion))
+	case strings.HasPrefix(line, DirectiveFields):
+		fields := strings.Fields(strings.TrimPrefix(line, DirectiveFields))
+		if len(fields) > 0 {
+			h.Fields = fields
+		}
+	}
+
+	return true
+}
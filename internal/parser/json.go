@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// ParseJSONRecord from a line of CloudFront standard logging (v2) JSON output.
+// Fields are kept in the order they appear in the object.
+func ParseJSONRecord(line []byte) (*AccessLogRecord, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrJSON, err)
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("%w: expected an object", ErrJSON)
+	}
+
+	var fields, values []string
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrJSON, err)
+		}
+
+		name, ok := token.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: expected a field name", ErrJSON)
+		}
+
+		var value any
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("%w: field %s: %s", ErrJSON, name, err)
+		}
+
+		fields = append(fields, name)
+		values = append(values, formatJSONValue(value))
+	}
+
+	record, err := NewRecord(fields, values)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a date the record would be pushed with an epoch timestamp, which is rejected along with its batch.
+	if !record.has("date") && !record.has("timestamp") && !record.has("timestamp(ms)") {
+		return nil, fmt.Errorf("%w: no timestamp", ErrDate)
+	}
+
+	return record, nil
+}
+
+// formatJSONValue converts a JSON value into its CloudFront log line representation.
+func formatJSONValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return Empty
+	case string:
+		if v == "" {
+			return Empty
+		}
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
@@ -35,7 +35,22 @@ func GetLogGroupName(key string) string {
 	keyParts := strings.Split(key, sep)
 
 	// LogGroup is the whole key excluding the filename.
-	logGroup := strings.Join(keyParts[:len(keyParts)-1], sep)
+	return logGroupName(keyParts[:len(keyParts)-1])
+}
+
+// GetPartitionedLogGroupName from the s3 object key, excluding the partition directories described by the layout, and
+// Hive-compatible partitions when there is a layout or hive is true.
+func GetPartitionedLogGroupName(key, layout string, hive bool) string {
+	keyParts := strings.Split(key, "/")
+
+	return logGroupName(TrimPartitions(keyParts[:len(keyParts)-1], layout, hive))
+}
+
+// logGroupName joins the directories of an s3 object key into a log group name.
+func logGroupName(dirs []string) string {
+	sep := "/"
+
+	logGroup := strings.Join(dirs, sep)
 
 	// Ensure the logGroup is prefixed with a slash.
 	if !strings.HasPrefix(logGroup, "/") {
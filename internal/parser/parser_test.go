@@ -25,3 +25,37 @@ func TestParseDateAndMessage(t *testing.T) {
 	expectedMessage := "SYD4-C2	35207	111.111.11.1	GET	asdasdasd.cloudfront.net	/admin/people	200	https://example.com/home	Mozilla/5.0%20(Macintosh;%20Intel%20Mac%20OS%20X%2010_14_5)%20AppleWebKit/537.36%20(KHTML,%20like%20Gecko)%20Chrome/83.0.4103.97%20Safari/537.36	-	-	Miss	oe49fbR4FcmNWieL3CVBnkQFZiNls0O9Zg24IfUYPWOXMX36hqQI4g==	dev.snsw-cos.snsw.skpr.dev	https	45	0.301	-	TLSv1.2	ECDHE-RSA-AES128-GCM-SHA256	Miss	HTTP/2.0	-	-	57856	0.299	Miss	text/html;%20charset=UTF-8	-	-	-"
 	assert.Equal(t, expectedMessage, message)
 }
+
+func TestGetPartitionedLogGroupName(t *testing.T) {
+	layout := "{DistributionId}/{yyyy}/{MM}/{dd}/{HH}"
+
+	logGroup := GetPartitionedLogGroupName("skpr/my-cluster/my-project/dev/E38J4Y0L8GXH9D/2024/06/25/10/E38J4Y0L8GXH9D.2024-06-25-10.d51ccc94.gz", layout, false)
+	assert.Equal(t, "/skpr/my-cluster/my-project/dev", logGroup)
+
+	// WAF logs are partitioned by minute.
+	logGroup = GetPartitionedLogGroupName("skpr/my-cluster/my-project/dev/AWSLogs/123456789012/WAFLogs/cloudfront/my-web-acl/2024/06/25/10/40/123456789012_waflogs_cloudfront_my-web-acl_20240625T1040Z_a1b2c3d4.log.gz", "AWSLogs/{AccountId}/WAFLogs/{Region}/{WebACL}/{yyyy}/{MM}/{dd}/{HH}/{mm}", false)
+	assert.Equal(t, "/skpr/my-cluster/my-project/dev", logGroup)
+
+	// Hive-compatible partitions are removed when enabled or there is a layout.
+	hiveKey := "skpr/my-cluster/my-project/dev/DistributionId=E38J4Y0L8GXH9D/year=2024/month=06/day=25/hour=10/E38J4Y0L8GXH9D.2024-06-25-10.d51ccc94.parquet"
+	logGroup = GetPartitionedLogGroupName(hiveKey, "", true)
+	assert.Equal(t, "/skpr/my-cluster/my-project/dev", logGroup)
+
+	logGroup = GetPartitionedLogGroupName(hiveKey, layout, false)
+	assert.Equal(t, "/skpr/my-cluster/my-project/dev", logGroup)
+
+	// Otherwise the log group of existing keys is left alone.
+	logGroup = GetPartitionedLogGroupName(hiveKey, "", false)
+	assert.Equal(t, "/skpr/my-cluster/my-project/dev/DistributionId=E38J4Y0L8GXH9D/year=2024/month=06/day=25/hour=10", logGroup)
+
+	// Keys which don't match the layout are left alone.
+	logGroup = GetPartitionedLogGroupName("skpr/my-cluster/my-project/dev/E38J4Y0L8GXH9D.2020-06-08-07.d51ccc94.gz", layout, false)
+	assert.Equal(t, "/skpr/my-cluster/my-project/dev", logGroup)
+}
+
+func TestTrimPartitions(t *testing.T) {
+	dirs := []string{"logs", "prod", "2024-06", "25"}
+	assert.Equal(t, []string{"logs", "prod"}, TrimPartitions(dirs, "{yyyy}-{MM}/{dd}", false))
+	assert.Equal(t, dirs, TrimPartitions(dirs, "{yyyy}/{MM}/{dd}", false))
+	assert.Equal(t, []string{"logs"}, TrimPartitions(dirs, "prod/{yyyy}-{MM}/{dd}", false))
+}
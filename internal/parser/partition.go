@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// partitionVariables are the patterns matched by the date variables of a partition layout.
+var partitionVariables = map[string]string{
+	"yyyy": `\d{4}`,
+	"MM":   `\d{2}`,
+	"dd":   `\d{2}`,
+	"HH":   `\d{2}`,
+	"mm":   `\d{2}`,
+}
+
+// TrimPartitions removes the partition directories described by the layout from the end of an s3 object key's directories.
+//
+// The layout uses the CloudFront standard logging (v2) variables, eg. {DistributionId}/{yyyy}/{MM}/{dd}/{HH}, where the
+// date variables match digits and any other variable matches any value. Hive-compatible partitions (eg. year=2024) are
+// removed when there is a layout or hive is true. Directories are left alone if they don't match the layout.
+func TrimPartitions(dirs []string, layout string, hive bool) []string {
+	segments := splitPath(layout)
+
+	if len(segments) > 0 && len(segments) <= len(dirs) {
+		tail := dirs[len(dirs)-len(segments):]
+		if matchesLayout(tail, segments) {
+			dirs = dirs[:len(dirs)-len(segments)]
+		}
+	}
+
+	if !hive && len(segments) == 0 {
+		// Keys without a layout keep the log group they have always had.
+		return dirs
+	}
+
+	for len(dirs) > 0 && isHivePartition(dirs[len(dirs)-1]) {
+		dirs = dirs[:len(dirs)-1]
+	}
+
+	return dirs
+}
+
+// matchesLayout returns true if each directory matches its layout segment.
+func matchesLayout(dirs, segments []string) bool {
+	for i, segment := range segments {
+		if !matchesSegment(dirs[i], segment) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesSegment returns true if a directory matches a layout segment.
+func matchesSegment(dir, segment string) bool {
+	var pattern strings.Builder
+
+	pattern.WriteString("^")
+
+	for segment != "" {
+		start := strings.Index(segment, "{")
+		end := strings.Index(segment, "}")
+
+		if start < 0 || end < start {
+			pattern.WriteString(regexp.QuoteMeta(segment))
+			break
+		}
+
+		pattern.WriteString(regexp.QuoteMeta(segment[:start]))
+
+		if variable, ok := partitionVariables[segment[start+1:end]]; ok {
+			pattern.WriteString(variable)
+		} else {
+			pattern.WriteString(".+")
+		}
+
+		segment = segment[end+1:]
+	}
+
+	pattern.WriteString("$")
+
+	matched, err := regexp.MatchString(pattern.String(), dir)
+
+	return err == nil && matched
+}
+
+// isHivePartition returns true if the directory is a hive-compatible partition, eg. year=2024.
+func isHivePartition(dir string) bool {
+	name, value, ok := strings.Cut(dir, "=")
+	return ok && name != "" && value != ""
+}
+
+// splitPath splits a path into its non-empty segments.
+func splitPath(path string) []string {
+	var segments []string
+
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	return segments
+}
@@ -0,0 +1,51 @@
+package parser
+
+// RealtimeFields are all of the fields available to a CloudFront real-time log configuration, in the order they are delivered.
+// Real-time logs have no header, so the fields of a configuration which selects a subset of these must be provided.
+var RealtimeFields = []string{
+	"timestamp",
+	"c-ip",
+	"time-to-first-byte",
+	"sc-status",
+	"sc-bytes",
+	"cs-method",
+	"cs-protocol",
+	"cs-host",
+	"cs-uri-stem",
+	"cs-bytes",
+	"x-edge-location",
+	"x-edge-request-id",
+	"x-host-header",
+	"time-taken",
+	"cs-protocol-version",
+	"c-ip-version",
+	"cs-user-agent",
+	"cs-referer",
+	"cs-cookie",
+	"cs-uri-query",
+	"x-edge-response-result-type",
+	"x-forwarded-for",
+	"ssl-protocol",
+	"ssl-cipher",
+	"x-edge-result-type",
+	"fle-encrypted-fields",
+	"fle-status",
+	"sc-content-type",
+	"sc-content-len",
+	"sc-range-start",
+	"sc-range-end",
+	"c-port",
+	"x-edge-detailed-result-type",
+	"c-country",
+	"cs-accept-encoding",
+	"cs-accept",
+	"cache-behavior-path-pattern",
+	"cs-headers",
+	"cs-header-names",
+	"cs-headers-count",
+	"primary-distribution-id",
+	"primary-distribution-dns-name",
+	"origin-fbl",
+	"origin-lbl",
+	"asn",
+}
@@ -0,0 +1,591 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// Separator between columns of a CloudFront log line.
+	Separator = "\t"
+	// Empty is the value CloudFront writes for a column without a value.
+	Empty = "-"
+)
+
+var (
+	// ErrShortLine is returned when a line has fewer columns than the header, eg. when it has been truncated.
+	ErrShortLine = errors.New("line is missing columns")
+	// ErrColumnCount is returned when a line has more columns than the header.
+	ErrColumnCount = errors.New("column count does not match fields")
+	// ErrDate is returned when the date or time of a line cannot be parsed.
+	ErrDate = errors.New("unable to parse date")
+	// ErrValue is returned when the value of a typed column cannot be parsed.
+	ErrValue = errors.New("unable to parse value")
+	// ErrJSON is returned when a line is not a JSON object.
+	ErrJSON = errors.New("unable to parse json")
+)
+
+// AccessLogRecord is a single CloudFront access log line.
+type AccessLogRecord struct {
+	// Timestamp of the request, combined from the date and time columns.
+	Timestamp time.Time
+	// EdgeLocation which served the request (x-edge-location).
+	EdgeLocation string
+	// SCBytes sent to the viewer (sc-bytes).
+	SCBytes *int64
+	// ClientIP of the viewer (c-ip).
+	ClientIP string
+	// Method of the request (cs-method).
+	Method string
+	// Host is the CloudFront domain name (cs(Host)).
+	Host string
+	// URIStem is the path of the request (cs-uri-stem).
+	URIStem string
+	// Status code of the response (sc-status).
+	Status *int64
+	// Referer of the request (cs(Referer)).
+	Referer string
+	// UserAgent of the viewer (cs(User-Agent)).
+	UserAgent string
+	// URIQuery is the query string of the request (cs-uri-query).
+	URIQuery string
+	// Cookie header of the request (cs(Cookie)).
+	Cookie string
+	// EdgeResultType is how the request was classified (x-edge-result-type).
+	EdgeResultType string
+	// EdgeRequestID uniquely identifies the request (x-edge-request-id).
+	EdgeRequestID string
+	// HostHeader sent by the viewer (x-host-header).
+	HostHeader string
+	// Protocol of the request (cs-protocol).
+	Protocol string
+	// CSBytes received from the viewer (cs-bytes).
+	CSBytes *int64
+	// TimeTaken in seconds to serve the request (time-taken).
+	TimeTaken *float64
+	// ForwardedFor is the X-Forwarded-For header of the request (x-forwarded-for).
+	ForwardedFor string
+	// SSLProtocol negotiated with the viewer (ssl-protocol).
+	SSLProtocol string
+	// SSLCipher negotiated with the viewer (ssl-cipher).
+	SSLCipher string
+	// EdgeResponseResultType is how the response was classified (x-edge-response-result-type).
+	EdgeResponseResultType string
+	// ProtocolVersion of the request (cs-protocol-version).
+	ProtocolVersion string
+	// FLEStatus is the field-level encryption status (fle-status).
+	FLEStatus string
+	// FLEEncryptedFields is the number of field-level encrypted fields (fle-encrypted-fields).
+	FLEEncryptedFields *int64
+	// ClientPort of the viewer (c-port).
+	ClientPort *int64
+	// TimeToFirstByte in seconds (time-to-first-byte).
+	TimeToFirstByte *float64
+	// EdgeDetailedResultType is a detailed classification of the response (x-edge-detailed-result-type).
+	EdgeDetailedResultType string
+	// ContentType of the response (sc-content-type).
+	ContentType string
+	// ContentLength of the response (sc-content-len).
+	ContentLength *int64
+	// RangeStart of a range request (sc-range-start).
+	RangeStart *int64
+	// RangeEnd of a range request (sc-range-end).
+	RangeEnd *int64
+	// Extra holds fields which are not known to this parser, such as new columns and enrichments, keyed by field name.
+	Extra map[string]any
+
+	// fields in the order they were parsed.
+	fields []string
+	// raw values of fields which have been decoded.
+	raw map[string]string
+	// named fields which are serialised to JSON using their name as is.
+	named map[string]bool
+}
+
+// ParseRecord from a cloudfront log line using the fields declared by the log header.
+func ParseRecord(fields []string, line string) (*AccessLogRecord, error) {
+	return NewRecord(fields, strings.Split(line, Separator))
+}
+
+// NewRecord from field names and their CloudFront log line representation.
+func NewRecord(fields, values []string) (*AccessLogRecord, error) {
+	if len(values) < len(fields) {
+		return nil, fmt.Errorf("%w: got %d columns, expected %d", ErrShortLine, len(values), len(fields))
+	}
+
+	if len(values) > len(fields) {
+		return nil, fmt.Errorf("%w: got %d columns, expected %d", ErrColumnCount, len(values), len(fields))
+	}
+
+	record := &AccessLogRecord{}
+
+	for i, name := range fields {
+		if err := record.Set(name, values[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return record, nil
+}
+
+// Fields returns the field names of the record in column order.
+func (r *AccessLogRecord) Fields() []string {
+	return r.fields
+}
+
+// Get the value of a field as it would appear in a CloudFront log line.
+func (r *AccessLogRecord) Get(name string) string {
+	if f, ok := knownFields[name]; ok {
+		return f.format(r)
+	}
+
+	if value := r.Value(name); value != nil {
+		return formatValue(value)
+	}
+
+	return Empty
+}
+
+// Set the value of a field from its CloudFront log line representation.
+func (r *AccessLogRecord) Set(name, value string) error {
+	if !r.has(name) {
+		r.fields = append(r.fields, name)
+	}
+
+	if f, ok := knownFields[name]; ok {
+		if value == Empty {
+			value = ""
+		}
+
+		return f.parse(r, value)
+	}
+
+	r.SetValue(name, value)
+
+	return nil
+}
+
+// SetValue sets a typed value for a field which is not known to this parser, eg. an enrichment.
+func (r *AccessLogRecord) SetValue(name string, value any) {
+	if _, ok := knownFields[name]; ok {
+		// Known fields are always parsed from their log line representation.
+		_ = r.Set(name, formatValue(value))
+		return
+	}
+
+	if !r.has(name) {
+		r.fields = append(r.fields, name)
+	}
+
+	if r.Extra == nil {
+		r.Extra = make(map[string]any)
+	}
+
+	r.Extra[name] = value
+}
+
+// SetNamedValue sets a typed value for a field which is serialised to JSON using its name as is, rather than a
+// lowercase key, eg. the camelCase fields of a JSON log.
+func (r *AccessLogRecord) SetNamedValue(name string, value any) {
+	r.SetValue(name, value)
+
+	if _, ok := knownFields[name]; ok {
+		return
+	}
+
+	if r.named == nil {
+		r.named = make(map[string]bool)
+	}
+
+	r.named[name] = true
+}
+
+// Remove a field from the record, clearing its value. The timestamp is kept as it is carried by the event.
+func (r *AccessLogRecord) Remove(name string) {
+	for i, field := range r.fields {
+		if field == name {
+			r.fields = append(r.fields[:i:i], r.fields[i+1:]...)
+			break
+		}
+	}
+
+	if f, ok := knownFields[name]; ok && !isTimestampField(name) {
+		_ = f.parse(r, "")
+	}
+
+	delete(r.Extra, name)
+	delete(r.raw, name)
+	delete(r.named, name)
+}
+
+// Key returns the name of a field when the record is serialised to JSON.
+func Key(name string) string {
+	if f, ok := knownFields[name]; ok {
+		return f.key
+	}
+
+	key := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, name)
+
+	return strings.Trim(key, "_")
+}
+
+// Key returns the name of a field of the record when it is serialised to JSON.
+func (r *AccessLogRecord) Key(name string) string {
+	if r.named[name] {
+		return name
+	}
+
+	return Key(name)
+}
+
+// Value returns the typed value of a field, or nil if it has no value.
+func (r *AccessLogRecord) Value(name string) any {
+	if f, ok := knownFields[name]; ok {
+		return f.value(r)
+	}
+
+	switch value := r.Extra[name].(type) {
+	case nil:
+		return nil
+	case string:
+		if value == "" || value == Empty {
+			return nil
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+// Message returns the record as a tab separated line, without the date and time which are carried by the timestamp.
+func (r *AccessLogRecord) Message() string {
+	values := make([]string, 0, len(r.fields))
+
+	for _, name := range r.fields {
+		if isTimestampField(name) {
+			continue
+		}
+
+		values = append(values, r.Get(name))
+	}
+
+	return strings.Join(values, Separator)
+}
+
+// JSON returns the record as a JSON object, without the date and time which are carried by the timestamp.
+func (r *AccessLogRecord) JSON() (string, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for _, name := range r.fields {
+		if isTimestampField(name) {
+			continue
+		}
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(r.Key(name))
+		if err != nil {
+			return "", err
+		}
+
+		value, err := json.Marshal(r.Value(name))
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal %s: %w", name, err)
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.String(), nil
+}
+
+// formatValue formats a typed value as it would appear in a CloudFront log line.
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return Empty
+	case string:
+		if v == "" {
+			return Empty
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []string:
+		if len(v) == 0 {
+			return Empty
+		}
+		return strings.Join(v, ",")
+	case map[string]any, []any:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// isTimestampField returns true if the field is carried by the event timestamp rather than the message.
+func isTimestampField(name string) bool {
+	return name == "date" || name == "time" || name == "timestamp" || name == "timestamp(ms)"
+}
+
+// has returns true if the record has the field.
+func (r *AccessLogRecord) has(name string) bool {
+	for _, field := range r.fields {
+		if field == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// field knows how to convert a column to and from its typed representation.
+type field struct {
+	key    string
+	parse  func(r *AccessLogRecord, value string) error
+	format func(r *AccessLogRecord) string
+	value  func(r *AccessLogRecord) any
+}
+
+// knownFields are the CloudFront fields which map onto the AccessLogRecord.
+var knownFields = map[string]field{
+	"date": {
+		key: "date",
+		parse: func(r *AccessLogRecord, value string) error {
+			date, err := time.Parse(time.DateOnly, value)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrDate, value)
+			}
+			r.Timestamp = time.Date(date.Year(), date.Month(), date.Day(), r.Timestamp.Hour(), r.Timestamp.Minute(), r.Timestamp.Second(), r.Timestamp.Nanosecond(), time.UTC)
+			return nil
+		},
+		format: func(r *AccessLogRecord) string {
+			return r.Timestamp.Format(time.DateOnly)
+		},
+		value: func(r *AccessLogRecord) any {
+			return r.Timestamp.Format(time.DateOnly)
+		},
+	},
+	"time": {
+		key: "time",
+		parse: func(r *AccessLogRecord, value string) error {
+			clock, err := time.Parse(time.TimeOnly, value)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrDate, value)
+			}
+			r.Timestamp = time.Date(r.Timestamp.Year(), r.Timestamp.Month(), r.Timestamp.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, time.UTC)
+			return nil
+		},
+		format: func(r *AccessLogRecord) string {
+			return r.Timestamp.Format(time.TimeOnly)
+		},
+		value: func(r *AccessLogRecord) any {
+			return r.Timestamp.Format(time.TimeOnly)
+		},
+	},
+	"x-edge-location":             stringField("edge_location", func(r *AccessLogRecord) *string { return &r.EdgeLocation }),
+	"sc-bytes":                    intField("sc_bytes", func(r *AccessLogRecord) **int64 { return &r.SCBytes }),
+	"c-ip":                        stringField("client_ip", func(r *AccessLogRecord) *string { return &r.ClientIP }),
+	"cs-method":                   stringField("method", func(r *AccessLogRecord) *string { return &r.Method }),
+	"cs(Host)":                    stringField("host", func(r *AccessLogRecord) *string { return &r.Host }),
+	"cs-uri-stem":                 stringField("uri_stem", func(r *AccessLogRecord) *string { return &r.URIStem }),
+	"sc-status":                   intField("status", func(r *AccessLogRecord) **int64 { return &r.Status }),
+	"cs(Referer)":                 stringField("referer", func(r *AccessLogRecord) *string { return &r.Referer }),
+	"cs(User-Agent)":              stringField("user_agent", func(r *AccessLogRecord) *string { return &r.UserAgent }),
+	"cs-uri-query":                stringField("uri_query", func(r *AccessLogRecord) *string { return &r.URIQuery }),
+	"cs(Cookie)":                  stringField("cookie", func(r *AccessLogRecord) *string { return &r.Cookie }),
+	"x-edge-result-type":          stringField("edge_result_type", func(r *AccessLogRecord) *string { return &r.EdgeResultType }),
+	"x-edge-request-id":           stringField("edge_request_id", func(r *AccessLogRecord) *string { return &r.EdgeRequestID }),
+	"x-host-header":               stringField("host_header", func(r *AccessLogRecord) *string { return &r.HostHeader }),
+	"cs-protocol":                 stringField("protocol", func(r *AccessLogRecord) *string { return &r.Protocol }),
+	"cs-bytes":                    intField("cs_bytes", func(r *AccessLogRecord) **int64 { return &r.CSBytes }),
+	"time-taken":                  floatField("time_taken", func(r *AccessLogRecord) **float64 { return &r.TimeTaken }),
+	"x-forwarded-for":             stringField("forwarded_for", func(r *AccessLogRecord) *string { return &r.ForwardedFor }),
+	"ssl-protocol":                stringField("ssl_protocol", func(r *AccessLogRecord) *string { return &r.SSLProtocol }),
+	"ssl-cipher":                  stringField("ssl_cipher", func(r *AccessLogRecord) *string { return &r.SSLCipher }),
+	"x-edge-response-result-type": stringField("edge_response_result_type", func(r *AccessLogRecord) *string { return &r.EdgeResponseResultType }),
+	"cs-protocol-version":         stringField("protocol_version", func(r *AccessLogRecord) *string { return &r.ProtocolVersion }),
+	"fle-status":                  stringField("fle_status", func(r *AccessLogRecord) *string { return &r.FLEStatus }),
+	"fle-encrypted-fields":        intField("fle_encrypted_fields", func(r *AccessLogRecord) **int64 { return &r.FLEEncryptedFields }),
+	"c-port":                      intField("client_port", func(r *AccessLogRecord) **int64 { return &r.ClientPort }),
+	"time-to-first-byte":          floatField("time_to_first_byte", func(r *AccessLogRecord) **float64 { return &r.TimeToFirstByte }),
+	"x-edge-detailed-result-type": stringField("edge_detailed_result_type", func(r *AccessLogRecord) *string { return &r.EdgeDetailedResultType }),
+	"sc-content-type":             stringField("content_type", func(r *AccessLogRecord) *string { return &r.ContentType }),
+	"sc-content-len":              intField("content_length", func(r *AccessLogRecord) **int64 { return &r.ContentLength }),
+	"sc-range-start":              intField("range_start", func(r *AccessLogRecord) **int64 { return &r.RangeStart }),
+	"sc-range-end":                intField("range_end", func(r *AccessLogRecord) **int64 { return &r.RangeEnd }),
+
+	// Real-time logs name some fields differently and carry a single timestamp.
+	"timestamp": {
+		key: "timestamp",
+		parse: func(r *AccessLogRecord, value string) error {
+			timestamp, err := parseEpoch(value)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrDate, value)
+			}
+			r.Timestamp = timestamp
+			return nil
+		},
+		format: func(r *AccessLogRecord) string {
+			return formatEpoch(r.Timestamp)
+		},
+		value: func(r *AccessLogRecord) any {
+			return formatEpoch(r.Timestamp)
+		},
+	},
+	"timestamp(ms)": {
+		key: "timestamp_ms",
+		parse: func(r *AccessLogRecord, value string) error {
+			ms, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrDate, value)
+			}
+			r.Timestamp = time.UnixMilli(ms).UTC()
+			return nil
+		},
+		format: func(r *AccessLogRecord) string {
+			return strconv.FormatInt(r.Timestamp.UnixMilli(), 10)
+		},
+		value: func(r *AccessLogRecord) any {
+			return r.Timestamp.UnixMilli()
+		},
+	},
+	"cs-host":       stringField("host", func(r *AccessLogRecord) *string { return &r.Host }),
+	"cs-referer":    stringField("referer", func(r *AccessLogRecord) *string { return &r.Referer }),
+	"cs-user-agent": stringField("user_agent", func(r *AccessLogRecord) *string { return &r.UserAgent }),
+	"cs-cookie":     stringField("cookie", func(r *AccessLogRecord) *string { return &r.Cookie }),
+}
+
+// parseEpoch parses seconds since the epoch with millisecond precision, eg. 1589496321.123
+func parseEpoch(value string) (time.Time, error) {
+	seconds, fraction, _ := strings.Cut(value, ".")
+
+	sec, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var nsec int64
+
+	if fraction != "" {
+		// Pad or truncate the fraction to nanoseconds.
+		fraction = (fraction + "000000000")[:9]
+		nsec, err = strconv.ParseInt(fraction, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	return time.Unix(sec, nsec).UTC(), nil
+}
+
+// formatEpoch formats a time as seconds since the epoch with millisecond precision.
+func formatEpoch(t time.Time) string {
+	return fmt.Sprintf("%d.%03d", t.Unix(), t.Nanosecond()/int(time.Millisecond))
+}
+
+// stringField maps a column onto a string.
+func stringField(key string, ptr func(r *AccessLogRecord) *string) field {
+	return field{
+		key: key,
+		parse: func(r *AccessLogRecord, value string) error {
+			*ptr(r) = value
+			return nil
+		},
+		format: func(r *AccessLogRecord) string {
+			if value := *ptr(r); value != "" {
+				return value
+			}
+			return Empty
+		},
+		value: func(r *AccessLogRecord) any {
+			if value := *ptr(r); value != "" {
+				return value
+			}
+			return nil
+		},
+	}
+}
+
+// intField maps a column onto an optional integer.
+func intField(key string, ptr func(r *AccessLogRecord) **int64) field {
+	return field{
+		key: key,
+		parse: func(r *AccessLogRecord, value string) error {
+			if value == "" {
+				*ptr(r) = nil
+				return nil
+			}
+			i, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%w: %s is not an integer", ErrValue, value)
+			}
+			*ptr(r) = &i
+			return nil
+		},
+		format: func(r *AccessLogRecord) string {
+			if value := *ptr(r); value != nil {
+				return strconv.FormatInt(*value, 10)
+			}
+			return Empty
+		},
+		value: func(r *AccessLogRecord) any {
+			if value := *ptr(r); value != nil {
+				return *value
+			}
+			return nil
+		},
+	}
+}
+
+// floatField maps a column onto an optional number of seconds.
+func floatField(key string, ptr func(r *AccessLogRecord) **float64) field {
+	return field{
+		key: key,
+		parse: func(r *AccessLogRecord, value string) error {
+			if value == "" {
+				*ptr(r) = nil
+				return nil
+			}
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("%w: %s is not a number", ErrValue, value)
+			}
+			*ptr(r) = &f
+			return nil
+		},
+		format: func(r *AccessLogRecord) string {
+			if value := *ptr(r); value != nil {
+				// CloudFront reports durations to the millisecond.
+				return strconv.FormatFloat(*value, 'f', 3, 64)
+			}
+			return Empty
+		},
+		value: func(r *AccessLogRecord) any {
+			if value := *ptr(r); value != nil {
+				return *value
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,162 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testLine = "2020-06-18	03:38:13	SYD4-C2	35207	111.111.11.1	GET	asdasdasd.cloudfront.net	/admin/people	200	https://example.com/home	Mozilla/5.0%20(Macintosh;%20Intel%20Mac%20OS%20X%2010_14_5)%20AppleWebKit/537.36%20(KHTML,%20like%20Gecko)%20Chrome/83.0.4103.97%20Safari/537.36	-	-	Miss	oe49fbR4FcmNWieL3CVBnkQFZiNls0O9Zg24IfUYPWOXMX36hqQI4g==	dev.snsw-cos.snsw.skpr.dev	https	45	0.301	-	TLSv1.2	ECDHE-RSA-AES128-GCM-SHA256	Miss	HTTP/2.0	-	-	57856	0.299	Miss	text/html;%20charset=UTF-8	-	-	-"
+
+func TestHeader_ParseDirective(t *testing.T) {
+	header := NewHeader()
+	assert.Equal(t, DefaultFields, header.Fields)
+
+	assert.True(t, header.ParseDirective("#Version: 1.0"))
+	assert.True(t, header.ParseDirective("#Fields: date time x-edge-location sc-status"))
+	assert.False(t, header.ParseDirective("2020-06-18	03:38:13	SYD4-C2	200"))
+
+	assert.Equal(t, "1.0", header.Version)
+	assert.Equal(t, []string{"date", "time", "x-edge-location", "sc-status"}, header.Fields)
+}
+
+func TestParseRecord(t *testing.T) {
+	record, err := ParseRecord(DefaultFields, testLine)
+	assert.NoError(t, err)
+
+	expectedDate, _ := time.Parse("2006-01-02 15:04:05", "2020-06-18 03:38:13")
+	assert.Equal(t, expectedDate, record.Timestamp)
+	assert.Equal(t, "SYD4-C2", record.EdgeLocation)
+	assert.Equal(t, int64(35207), *record.SCBytes)
+	assert.Equal(t, "111.111.11.1", record.ClientIP)
+	assert.Equal(t, "GET", record.Method)
+	assert.Equal(t, "/admin/people", record.URIStem)
+	assert.Equal(t, int64(200), *record.Status)
+	assert.Equal(t, 0.301, *record.TimeTaken)
+	assert.Equal(t, "TLSv1.2", record.SSLProtocol)
+	assert.Equal(t, int64(57856), *record.ClientPort)
+	assert.Equal(t, "", record.URIQuery)
+	assert.Nil(t, record.RangeStart)
+	assert.Equal(t, DefaultFields, record.Fields())
+
+	// The message is the original line without the date and time.
+	assert.Equal(t, strings.SplitN(testLine, Separator, 3)[2], record.Message())
+}
+
+func TestParseRecord_FieldOrder(t *testing.T) {
+	fields := []string{"time", "date", "sc-status", "x-edge-location", "x-new-field"}
+
+	record, err := ParseRecord(fields, "03:38:13	2020-06-18	404	FRA2	something")
+	assert.NoError(t, err)
+
+	expectedDate, _ := time.Parse("2006-01-02 15:04:05", "2020-06-18 03:38:13")
+	assert.Equal(t, expectedDate, record.Timestamp)
+	assert.Equal(t, int64(404), *record.Status)
+	assert.Equal(t, "FRA2", record.EdgeLocation)
+	assert.Equal(t, "something", record.Extra["x-new-field"])
+	assert.Equal(t, "404	FRA2	something", record.Message())
+}
+
+func TestParseRecord_Errors(t *testing.T) {
+	_, err := ParseRecord(DefaultFields, "2020-06-18	03:38:13	SYD4-C2")
+	assert.ErrorIs(t, err, ErrShortLine)
+
+	_, err = ParseRecord([]string{"date", "time"}, "2020-06-18	03:38:13	SYD4-C2")
+	assert.ErrorIs(t, err, ErrColumnCount)
+
+	_, err = ParseRecord([]string{"sc-status"}, "OK")
+	assert.ErrorIs(t, err, ErrValue)
+
+	_, err = ParseRecord([]string{"date", "time"}, "2020-06-18	nope")
+	assert.ErrorIs(t, err, ErrDate)
+}
+
+func TestAccessLogRecord_JSON(t *testing.T) {
+	fields := []string{"date", "time", "x-edge-location", "sc-bytes", "cs-uri-query", "time-taken", "cs(Accept-Encoding)"}
+
+	record, err := ParseRecord(fields, "2020-06-18	03:38:13	SYD4-C2	35207	-	0.301	gzip")
+	assert.NoError(t, err)
+
+	message, err := record.JSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"edge_location":"SYD4-C2","sc_bytes":35207,"uri_query":null,"time_taken":0.301,"cs_accept_encoding":"gzip"}`, message)
+}
+
+func TestAccessLogRecord_SetNamedValue(t *testing.T) {
+	record := &AccessLogRecord{}
+	record.SetNamedValue("terminatingRuleId", "RateLimit")
+	record.SetNamedValue("httpRequest.host", "www.example.com")
+	record.SetNamedValue("c-ip", "192.0.2.10")
+	record.SetValue("ruleGroupList", []any{})
+
+	// Named fields keep their name as their key, while known and other fields use their usual key.
+	message, err := record.JSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"terminatingRuleId":"RateLimit","httpRequest.host":"www.example.com","client_ip":"192.0.2.10","rulegrouplist":[]}`, message)
+
+	record.Remove("terminatingRuleId")
+	record.SetValue("terminatingRuleId", "Default_Action")
+	assert.Equal(t, "terminatingruleid", record.Key("terminatingRuleId"))
+}
+
+func TestAccessLogRecord_Remove(t *testing.T) {
+	fields := []string{"date", "time", "x-edge-location", "sc-status", "ssl-cipher", "x-new-field"}
+
+	record, err := ParseRecord(fields, "2020-06-18	03:38:13	SYD4-C2	200	ECDHE-RSA-AES128-GCM-SHA256	something")
+	assert.NoError(t, err)
+
+	record.Remove("sc-status")
+	record.Remove("x-new-field")
+	record.Remove("date")
+	record.Remove("missing")
+
+	assert.Nil(t, record.Status)
+	assert.NotContains(t, record.Extra, "x-new-field")
+	assert.Equal(t, []string{"time", "x-edge-location", "ssl-cipher"}, record.Fields())
+	assert.Equal(t, "SYD4-C2	ECDHE-RSA-AES128-GCM-SHA256", record.Message())
+
+	// The timestamp is kept.
+	assert.Equal(t, time.Date(2020, 6, 18, 3, 38, 13, 0, time.UTC), record.Timestamp)
+}
+
+func TestParseRecord_Realtime(t *testing.T) {
+	fields := []string{"timestamp", "c-ip", "sc-status", "cs-host", "cs-user-agent", "c-country"}
+
+	record, err := ParseRecord(fields, "1589496321.123	192.0.2.10	200	d111111abcdef8.cloudfront.net	curl/7.68.0	AU")
+	assert.NoError(t, err)
+
+	assert.Equal(t, time.UnixMilli(1589496321123).UTC(), record.Timestamp)
+	assert.Equal(t, "192.0.2.10", record.ClientIP)
+	assert.Equal(t, int64(200), *record.Status)
+	assert.Equal(t, "d111111abcdef8.cloudfront.net", record.Host)
+	assert.Equal(t, "curl/7.68.0", record.UserAgent)
+	assert.Equal(t, "AU", record.Extra["c-country"])
+	assert.Equal(t, "192.0.2.10	200	d111111abcdef8.cloudfront.net	curl/7.68.0	AU", record.Message())
+}
+
+func TestParseJSONRecord(t *testing.T) {
+	line := `{"timestamp(ms)":"1719309600123","DistributionId":"E38J4Y0L8GXH9D","x-edge-location":"SYD4-C2","sc-status":200,"time-taken":"0.301","cs-uri-query":null}`
+
+	record, err := ParseJSONRecord([]byte(line))
+	assert.NoError(t, err)
+
+	assert.Equal(t, time.UnixMilli(1719309600123).UTC(), record.Timestamp)
+	assert.Equal(t, "E38J4Y0L8GXH9D", record.Extra["DistributionId"])
+	assert.Equal(t, "SYD4-C2", record.EdgeLocation)
+	assert.Equal(t, int64(200), *record.Status)
+	assert.Equal(t, 0.301, *record.TimeTaken)
+	assert.Equal(t, "", record.URIQuery)
+	assert.Equal(t, []string{"timestamp(ms)", "DistributionId", "x-edge-location", "sc-status", "time-taken", "cs-uri-query"}, record.Fields())
+
+	_, err = ParseJSONRecord([]byte(`["not", "an", "object"]`))
+	assert.Error(t, err)
+
+	// Records without a timestamp can't be pushed.
+	_, err = ParseJSONRecord([]byte(`{"x-edge-location":"SYD4-C2","sc-status":200}`))
+	assert.ErrorIs(t, err, ErrDate)
+
+	_, err = ParseJSONRecord([]byte(`{"time":"03:38:13","sc-status":200}`))
+	assert.ErrorIs(t, err, ErrDate)
+}
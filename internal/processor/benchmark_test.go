@@ -0,0 +1,71 @@
+//go:build linux
+
+package processor
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// benchmarkSize is the uncompressed size of the object processed by the benchmark.
+const benchmarkSize = 512 * 1024 * 1024
+
+// benchmarkLine is a line of a CloudFront standard log, with the time, client IP and bytes left to be filled in.
+const benchmarkLine = "2020-06-18	%02d:%02d:%02d	SYD4-C2	%d	111.111.%d.%d	GET	asdasdasd.cloudfront.net	/admin/people	200	https://example.com/home	Mozilla/5.0%%20(Macintosh;%%20Intel%%20Mac%%20OS%%20X%%2010_14_5)%%20AppleWebKit/537.36%%20(KHTML,%%20like%%20Gecko)%%20Chrome/83.0.4103.97%%20Safari/537.36	-	-	Miss	oe49fbR4FcmNWieL3CVBnkQFZiNls0O9Zg24IfUYPWOXMX36hqQI4g==	dev.snsw-cos.snsw.skpr.dev	https	45	0.301	-	TLSv1.2	ECDHE-RSA-AES128-GCM-SHA256	Miss	HTTP/2.0	-	-	57856	0.299	Miss	text/html;%%20charset=UTF-8	-	-	-\n"
+
+// writeObject writes a gzip compressed CloudFront standard log of the size to the writer.
+func writeObject(writer *io.PipeWriter, size int) {
+	gzipWriter, _ := gzip.NewWriterLevel(writer, gzip.BestSpeed)
+
+	written, _ := fmt.Fprint(gzipWriter, "#Version: 1.0\n")
+
+	for i := 0; written < size; i++ {
+		n, err := fmt.Fprintf(gzipWriter, benchmarkLine, i/3600%24, i/60%60, i%60, i, i/256%256, i%256)
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		written += n
+	}
+
+	writer.CloseWithError(gzipWriter.Close())
+}
+
+// BenchmarkProcessReader streams a large object, reporting the peak RSS of the process. Run it on its own to measure
+// the memory used by streaming rather than by other tests, eg.
+//
+//	go test ./internal/processor -run '^$' -bench ProcessReader -benchtime 1x
+func BenchmarkProcessReader(b *testing.B) {
+	b.SetBytes(benchmarkSize)
+
+	for i := 0; i < b.N; i++ {
+		reader, writer := io.Pipe()
+		go writeObject(writer, benchmarkSize)
+
+		var events int
+
+		err := ProcessReader(reader, Options{Output: OutputText}, func(event types.InputLogEvent) error {
+			events++
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if events == 0 {
+			b.Fatal("no events were processed")
+		}
+	}
+
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		b.Fatal(err)
+	}
+
+	// Linux reports the peak RSS in kilobytes.
+	b.ReportMetric(float64(usage.Maxrss)/1024, "peak-rss-MB")
+}
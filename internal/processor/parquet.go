@@ -0,0 +1,94 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// parquetBatchSize is the amount of rows read from a Parquet object at a time.
+const parquetBatchSize = 128
+
+// processParquet processes the rows of a CloudFront standard logging (v2) Parquet object.
+func processParquet(data []byte, options Options, processEvent func(event types.InputLogEvent) error) error {
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("error reading parquet: %w", err)
+	}
+
+	var fields []string
+
+	for _, path := range file.Schema().Columns() {
+		fields = append(fields, strings.Join(path, "."))
+	}
+
+	reader := parquet.NewReader(file)
+	defer reader.Close()
+
+	rows := make([]parquet.Row, parquetBatchSize)
+
+	var number int
+
+	for {
+		n, err := reader.ReadRows(rows)
+
+		for _, row := range rows[:n] {
+			number++
+			values := make([]string, len(fields))
+
+			for i := range values {
+				values[i] = parser.Empty
+			}
+
+			for _, value := range row {
+				values[value.Column()] = formatParquetValue(value)
+			}
+
+			record, err := parser.NewRecord(fields, values)
+			if err != nil {
+				err = processUnparsed(strings.Join(values, parser.Separator), number, err, options, processEvent)
+			} else {
+				err = processRecord(record, options, processEvent)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("error reading parquet rows: %w", err)
+		}
+	}
+}
+
+// formatParquetValue converts a Parquet value into its CloudFront log line representation.
+func formatParquetValue(value parquet.Value) string {
+	if value.IsNull() {
+		return parser.Empty
+	}
+
+	switch value.Kind() {
+	case parquet.Float:
+		return strconv.FormatFloat(float64(value.Float()), 'f', -1, 32)
+	case parquet.Double:
+		return strconv.FormatFloat(value.Double(), 'f', -1, 64)
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		if len(value.ByteArray()) == 0 {
+			return parser.Empty
+		}
+	}
+
+	return value.String()
+}
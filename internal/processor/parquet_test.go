@@ -0,0 +1,41 @@
+package processor
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/processor/mock"
+)
+
+// parquetRow is a subset of the fields of CloudFront standard logging (v2) Parquet output.
+type parquetRow struct {
+	Date         string  `parquet:"date"`
+	Time         string  `parquet:"time"`
+	EdgeLocation string  `parquet:"x-edge-location"`
+	Status       int64   `parquet:"sc-status"`
+	TimeTaken    float64 `parquet:"time-taken"`
+	Query        *string `parquet:"cs-uri-query,optional"`
+}
+
+func TestProcess_Parquet(t *testing.T) {
+	query := "foo=bar"
+
+	var buf bytes.Buffer
+	err := parquet.Write(&buf, []parquetRow{
+		{Date: "2024-06-25", Time: "10:00:01", EdgeLocation: "SYD4-C2", Status: 200, TimeTaken: 0.301},
+		{Date: "2024-06-25", Time: "10:00:02", EdgeLocation: "FRA2", Status: 404, TimeTaken: 0.002, Query: &query},
+	})
+	assert.NoError(t, err)
+
+	processor := mock.NewProcessor()
+	err = Process(buf.Bytes(), Options{Output: OutputText}, processor.Process)
+	assert.NoError(t, err)
+	logEvents := processor.GetEvents()
+	assert.Len(t, logEvents, 2)
+	assert.Equal(t, int64(1719309601000), *logEvents[0].Timestamp)
+	assert.Equal(t, "SYD4-C2	200	0.301	-", *logEvents[0].Message)
+	assert.Equal(t, "FRA2	404	0.002	foo=bar", *logEvents[1].Message)
+}
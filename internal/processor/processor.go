@@ -4,49 +4,375 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
 
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/format"
 	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
 )
 
+// Output is the format of the messages pushed to CloudWatch Logs.
+type Output string
+
+const (
+	// OutputText pushes the original tab separated line.
+	OutputText Output = "text"
+	// OutputJSON pushes each line as a JSON object with named fields.
+	OutputJSON Output = "json"
+)
+
+// Validate the output format.
+func (o Output) Validate() error {
+	switch o {
+	case OutputText, OutputJSON:
+		return nil
+	}
+
+	return fmt.Errorf("unsupported output format: %s", o)
+}
+
+// Options for processing lines.
+type Options struct {
+	// Format of the lines, defaulting to CloudFront standard logs.
+	Format format.Format
+	// Output is the format of each message.
+	Output Output
+	// Decode URL-encoded fields.
+	Decode bool
+	// Fields of real-time logs, which have no header.
+	Fields []string
+	// Timestamp policy for each event.
+	Timestamp TimestampPolicy
+	// Unparseable policy for lines which can't be parsed.
+	Unparseable UnparseablePolicy
+	// LastModified time of the object being processed.
+	LastModified time.Time
+	// Quarantine receives lines which can't be parsed when using the quarantine policy, along with their line number.
+	Quarantine func(line string, number int, reason error) error
+	// Enrichers add fields to each record before it is pushed.
+	Enrichers []Enricher
+	// Filters decide which records are pushed, in order.
+	Filters []Filter
+	// Observers receive each record which is kept by the filters, before it is redacted.
+	Observers []Observer
+	// Redactors remove sensitive values from each record before it is pushed.
+	Redactors []Redactor
+	// Projection selects the fields of each record which are pushed.
+	Projection Projection
+	// Projected receives the size of each message before and after the projection was applied.
+	Projected func(before, after int)
+}
+
+// Filter decides whether a parsed record is pushed.
+type Filter interface {
+	// Keep returns true if the record should be pushed.
+	Keep(record *parser.AccessLogRecord) (bool, error)
+}
+
+// Observer counts the records which are pushed.
+type Observer interface {
+	// Observe the record, leaving it unchanged.
+	Observe(record *parser.AccessLogRecord)
+}
+
+// Redactor removes sensitive values from a parsed record.
+type Redactor interface {
+	// Redact the record.
+	Redact(record *parser.AccessLogRecord) error
+}
+
+// LineRedactor removes sensitive values from a line which couldn't be parsed.
+type LineRedactor interface {
+	// RedactLine returns the line without sensitive values.
+	RedactLine(line string) string
+}
+
+// Enricher adds fields to a parsed record.
+type Enricher interface {
+	// Enrich the record.
+	Enrich(record *parser.AccessLogRecord) error
+}
+
+var (
+	// gzipMagic are the bytes at the start of a gzip compressed object.
+	gzipMagic = []byte{0x1f, 0x8b}
+	// parquetMagic are the bytes at the start of a Parquet object.
+	parquetMagic = []byte("PAR1")
+)
+
+const (
+	// maxLineSize is the longest line which can be processed.
+	maxLineSize = 1024 * 1024
+	// HeadSize is the amount of the start of an object which is used to detect its format.
+	HeadSize = 4096
+)
+
+// Process the contents of an s3 object, detecting whether it is a Parquet log and whether it is compressed.
+func Process(data []byte, options Options, processEvent func(event types.InputLogEvent) error) error {
+	return ProcessReader(bytes.NewReader(data), options, processEvent)
+}
+
+// ProcessReader streams the contents of an s3 object, detecting whether it is a Parquet log and whether it is
+// compressed. Lines are decompressed and processed as they are read, so memory use doesn't grow with the size of the
+// object. Parquet logs are the exception, as they are read into memory for random access.
+func ProcessReader(reader io.Reader, options Options, processEvent func(event types.InputLogEvent) error) error {
+	buffered := bufio.NewReaderSize(reader, HeadSize)
+
+	magic, err := buffered.Peek(len(parquetMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error reading object: %w", err)
+	}
+
+	if bytes.HasPrefix(magic, parquetMagic) {
+		data, err := io.ReadAll(buffered)
+		if err != nil {
+			return fmt.Errorf("error reading object: %w", err)
+		}
+
+		return processParquet(data, options, processEvent)
+	}
+
+	reader = buffered
+
+	if bytes.HasPrefix(magic, gzipMagic) {
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return fmt.Errorf("error reading gzip: %w", err)
+		}
+		defer gzipReader.Close()
+
+		reader = gzipReader
+	}
+
+	return processLines(reader, formatOf(options).NewParser(), options, processEvent)
+}
+
+// DetectFormat detects the format of an s3 object from its key and the start of its contents, using whether it is a
+// Parquet log and its first line. The fallback, or CloudFront standard logs if there is none, is returned along with
+// false if it can't be detected.
+func DetectFormat(key string, head []byte, fallback format.Format) (format.Format, bool) {
+	if fallback == nil {
+		fallback = format.CloudFront{}
+	}
+
+	if bytes.HasPrefix(head, parquetMagic) {
+		// Parquet logs are only written by CloudFront standard logging (v2).
+		return format.CloudFront{}, true
+	}
+
+	if bytes.HasPrefix(head, gzipMagic) {
+		gzipReader, err := gzip.NewReader(bytes.NewReader(head))
+		if err != nil {
+			return format.Detect(key, nil, fallback)
+		}
+		defer gzipReader.Close()
+
+		// The head is a partial gzip stream, so it is read until it runs out.
+		head, _ = io.ReadAll(io.LimitReader(gzipReader, HeadSize))
+	}
+
+	if line, _, ok := bytes.Cut(head, []byte("\n")); ok {
+		head = line
+	}
+
+	return format.Detect(key, head, fallback)
+}
+
 // ProcessLines processes the gzip buffer line by line.
-func ProcessLines(gzipBytes []byte, processEvent func(event types.InputLogEvent) error) error {
+func ProcessLines(gzipBytes []byte, options Options, processEvent func(event types.InputLogEvent) error) error {
 	gzipReader, err := gzip.NewReader(bytes.NewBuffer(gzipBytes))
 	if err != nil {
 		return fmt.Errorf("error reading gzip: %w", err)
 	}
 	defer gzipReader.Close()
 
-	scanner := bufio.NewScanner(gzipReader)
+	return processLines(gzipReader, formatOf(options).NewParser(), options, processEvent)
+}
+
+// ProcessRealtime processes CloudFront real-time log data, which has no header, using the configured fields.
+func ProcessRealtime(data []byte, options Options, processEvent func(event types.InputLogEvent) error) error {
+	return processLines(bytes.NewReader(data), format.NewCloudFrontRealtime(options.Fields).NewParser(), options, processEvent)
+}
+
+// formatOf returns the format of the options, defaulting to CloudFront standard logs.
+func formatOf(options Options) format.Format {
+	if options.Format == nil {
+		return format.CloudFront{}
+	}
+
+	return options.Format
+}
+
+// processLines parses each line using the parser and hands it on as a log event.
+func processLines(reader io.Reader, lineParser format.Parser, options Options, processEvent func(event types.InputLogEvent) error) error {
+	var number int
+
+	scanner := newScanner(reader)
 	for scanner.Scan() {
-		if len(scanner.Text()) < 1 {
+		number++
+		line := scanner.Text()
+		if len(strings.TrimSpace(line)) < 1 {
 			// Nothing in this line - probably just a newline.
 			continue
 		}
-		message := string(scanner.Text())
-		if strings.HasPrefix(string(message), "#") {
-			// Comment - ignore.
+		if lineParser.ParseHeader(line) {
+			// Header - describes the lines which follow.
 			continue
 		}
-		// Parse date out of cloudfront access log line.
-		date, message, err := parser.ParseDateAndMessage(message)
+		record, err := lineParser.ParseLine(line)
 		if err != nil {
-			// Couldn't parse date, default to now.
-			date = time.Now()
+			err = processUnparsed(line, number, err, options, processEvent)
+		} else {
+			err = processRecord(record, options, processEvent)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// processRecord hands a parsed record on as a log event.
+func processRecord(record *parser.AccessLogRecord, options Options, processEvent func(event types.InputLogEvent) error) error {
+	if options.Decode {
+		if err := record.Decode(); err != nil {
+			return err
 		}
-		event := types.InputLogEvent{
-			Message:   aws.String(message),
-			Timestamp: aws.Int64(date.UnixNano() / int64(time.Millisecond/time.Nanosecond)),
+	}
+
+	for _, enricher := range options.Enrichers {
+		if err := enricher.Enrich(record); err != nil {
+			return fmt.Errorf("failed to enrich record: %w", err)
+		}
+	}
+
+	for _, filter := range options.Filters {
+		keep, err := filter.Keep(record)
+		if err != nil {
+			return fmt.Errorf("failed to filter record: %w", err)
 		}
-		err = processEvent(event)
+		if !keep {
+			return nil
+		}
+	}
+
+	for _, observer := range options.Observers {
+		observer.Observe(record)
+	}
+
+	// The timestamp is taken before fields it depends on, eg. time-taken, can be redacted or projected away.
+	date := options.Timestamp.Timestamp(record)
+
+	for _, redactor := range options.Redactors {
+		if err := redactor.Redact(record); err != nil {
+			return fmt.Errorf("failed to redact record: %w", err)
+		}
+	}
+
+	if options.Decode && options.Output == OutputJSON {
+		// The raw values are added once redacted, so they can be projected like any other field.
+		record.AddRawFields()
+	}
+
+	message, err := project(record, options)
+	if err != nil {
+		return err
+	}
+
+	return pushEvent(date, message, processEvent)
+}
+
+// project applies the projection to the record, returning the message to be pushed.
+func project(record *parser.AccessLogRecord, options Options) (string, error) {
+	if !options.Projection.Enabled() {
+		return formatMessage(record, options.Output)
+	}
+
+	var before string
+
+	if options.Projected != nil {
+		// Only format the whole record when something is keeping count.
+		message, err := formatMessage(record, options.Output)
 		if err != nil {
-			return fmt.Errorf("failed to push log event: %w", err)
+			return "", err
+		}
+		before = message
+	}
+
+	options.Projection.Apply(record)
+
+	message, err := formatMessage(record, options.Output)
+	if err != nil {
+		return "", err
+	}
+
+	if options.Projected != nil {
+		options.Projected(len(before), len(message))
+	}
+
+	return message, nil
+}
+
+// processUnparsed handles a line which couldn't be parsed according to the unparseable policy.
+func processUnparsed(line string, number int, reason error, options Options, processEvent func(event types.InputLogEvent) error) error {
+	if options.Unparseable == UnparseableDrop {
+		return nil
+	}
+
+	for _, redactor := range options.Redactors {
+		if lineRedactor, ok := redactor.(LineRedactor); ok {
+			line = lineRedactor.RedactLine(line)
 		}
 	}
+
+	switch options.Unparseable {
+	case UnparseableQuarantine:
+		if options.Quarantine == nil {
+			return fmt.Errorf("no quarantine for unparseable line: %w", reason)
+		}
+		return options.Quarantine(line, number, reason)
+	default:
+		date := options.LastModified
+		if date.IsZero() {
+			// Nothing better to go on, default to now.
+			date = time.Now()
+		}
+		return pushEvent(date, line, processEvent)
+	}
+}
+
+// pushEvent hands on the message as a log event.
+func pushEvent(date time.Time, message string, processEvent func(event types.InputLogEvent) error) error {
+	event := types.InputLogEvent{
+		Message:   aws.String(message),
+		Timestamp: aws.Int64(date.UnixNano() / int64(time.Millisecond/time.Nanosecond)),
+	}
+	err := processEvent(event)
+	if err != nil {
+		return fmt.Errorf("failed to push log event: %w", err)
+	}
 	return nil
 }
+
+// formatMessage formats the record for the output.
+func formatMessage(record *parser.AccessLogRecord, output Output) (string, error) {
+	if output == OutputJSON {
+		return record.JSON()
+	}
+
+	return record.Message(), nil
+}
+
+// newScanner creates a line scanner which allows for long lines.
+func newScanner(reader io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, bufio.MaxScanTokenSize), maxLineSize)
+	return scanner
+}
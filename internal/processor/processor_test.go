@@ -1,19 +1,28 @@
 package processor
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
 	"io/ioutil"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/format"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
 	"github.com/skpr/cloudfront-cloudwatchlogs/internal/processor/mock"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/redact"
 )
 
 func TestProcessLines(t *testing.T) {
 	contents, err := ioutil.ReadFile("testdata/test-logs.gz")
 	assert.NoError(t, err)
 	processor := mock.NewProcessor()
-	err = ProcessLines(contents, processor.Process)
+	err = ProcessLines(contents, Options{Output: OutputText}, processor.Process)
 	assert.NoError(t, err)
 	logEvents := processor.GetEvents()
 	// Length should be number of lines minus 2 for the comments at the top.
@@ -23,3 +32,330 @@ func TestProcessLines(t *testing.T) {
 	// Logs are sorted in chronilogical order.
 	assert.Less(t, *logEvents[0].Timestamp, *logEvents[len(logEvents)-1].Timestamp)
 }
+
+func TestProcessLines_JSON(t *testing.T) {
+	contents, err := ioutil.ReadFile("testdata/test-logs.gz")
+	assert.NoError(t, err)
+	processor := mock.NewProcessor()
+	err = ProcessLines(contents, Options{Output: OutputJSON}, processor.Process)
+	assert.NoError(t, err)
+	logEvents := processor.GetEvents()
+	assert.Len(t, logEvents, 58)
+	// Messages should be JSON objects with typed fields.
+	var message map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(*logEvents[0].Message), &message))
+	assert.Equal(t, "SYD4-C2", message["edge_location"])
+	assert.Equal(t, float64(200), message["status"])
+	assert.Nil(t, message["uri_query"])
+}
+
+func TestProcessRealtime(t *testing.T) {
+	data := []byte("1589496321.123	192.0.2.10	200	/index.html\n1589496322.456	192.0.2.11	404	/missing.html\n")
+	processor := mock.NewProcessor()
+	options := Options{
+		Output: OutputText,
+		Fields: []string{"timestamp", "c-ip", "sc-status", "cs-uri-stem"},
+	}
+	err := ProcessRealtime(data, options, processor.Process)
+	assert.NoError(t, err)
+	logEvents := processor.GetEvents()
+	assert.Len(t, logEvents, 2)
+	// Timestamps keep their millisecond precision.
+	assert.Equal(t, int64(1589496321123), *logEvents[0].Timestamp)
+	assert.Equal(t, "192.0.2.11	404	/missing.html", *logEvents[1].Message)
+}
+
+func TestProcess(t *testing.T) {
+	contents, err := ioutil.ReadFile("testdata/test-logs.gz")
+	assert.NoError(t, err)
+
+	// Legacy gzip compressed logs.
+	processor := mock.NewProcessor()
+	err = Process(contents, Options{Output: OutputText}, processor.Process)
+	assert.NoError(t, err)
+	assert.Len(t, processor.GetEvents(), 58)
+
+	// Uncompressed logs.
+	processor = mock.NewProcessor()
+	err = Process([]byte("#Version: 1.0\n#Fields: date time sc-status\n2024-06-25	10:00:01	200\n"), Options{Output: OutputText}, processor.Process)
+	assert.NoError(t, err)
+	assert.Len(t, processor.GetEvents(), 1)
+	assert.Equal(t, "200", *processor.GetEvents()[0].Message)
+}
+
+func TestProcess_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write([]byte(`{"timestamp":"1719309601","x-edge-location":"SYD4-C2","sc-status":"200"}
+{"timestamp":"1719309602","x-edge-location":"FRA2","sc-status":"404"}
+`))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	processor := mock.NewProcessor()
+	err = Process(buf.Bytes(), Options{Output: OutputJSON}, processor.Process)
+	assert.NoError(t, err)
+	logEvents := processor.GetEvents()
+	assert.Len(t, logEvents, 2)
+	assert.Equal(t, int64(1719309602000), *logEvents[1].Timestamp)
+	assert.Equal(t, `{"edge_location":"FRA2","status":404}`, *logEvents[1].Message)
+}
+
+// enricherFunc adapts a function to an enricher.
+type enricherFunc func(record *parser.AccessLogRecord) error
+
+// Enrich implements the interface.
+func (f enricherFunc) Enrich(record *parser.AccessLogRecord) error {
+	return f(record)
+}
+
+func TestProcess_Enrichers(t *testing.T) {
+	options := Options{
+		Output: OutputJSON,
+		Enrichers: []Enricher{
+			enricherFunc(func(record *parser.AccessLogRecord) error {
+				record.SetValue("is_error", *record.Status >= 500)
+				return nil
+			}),
+		},
+	}
+
+	processor := mock.NewProcessor()
+	err := Process([]byte("#Fields: date time sc-status\n2024-06-25	10:00:01	503\n"), options, processor.Process)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"status":503,"is_error":true}`, *processor.GetEvents()[0].Message)
+
+	options.Enrichers = append(options.Enrichers, enricherFunc(func(record *parser.AccessLogRecord) error {
+		return errors.New("lookup failed")
+	}))
+	err = Process([]byte("#Fields: date time sc-status\n2024-06-25	10:00:01	503\n"), options, mock.NewProcessor().Process)
+	assert.ErrorContains(t, err, "lookup failed")
+}
+
+// upperRedactor redacts records and lines by upper casing them.
+type upperRedactor struct{}
+
+// Redact implements the interface.
+func (upperRedactor) Redact(record *parser.AccessLogRecord) error {
+	return record.Set("x-edge-location", strings.ToUpper(record.EdgeLocation))
+}
+
+// RedactLine implements the interface.
+func (upperRedactor) RedactLine(line string) string {
+	return strings.ToUpper(line)
+}
+
+func TestProcess_Redactors(t *testing.T) {
+	data := []byte("#Fields: date time x-edge-location\n2020-06-18	03:38:13	syd4-c2\nnot a log line\n")
+
+	processor := mock.NewProcessor()
+	err := Process(data, Options{Unparseable: UnparseableLastModified, Redactors: []Redactor{upperRedactor{}}}, processor.Process)
+	assert.NoError(t, err)
+	assert.Equal(t, "SYD4-C2", *processor.GetEvents()[0].Message)
+	// Lines which can't be parsed are redacted too.
+	assert.Equal(t, "NOT A LOG LINE", *processor.GetEvents()[1].Message)
+}
+
+func TestProcess_RedactQuarantined(t *testing.T) {
+	rules, err := redact.Parse([]byte(`
+- action: mask
+  parameters: [token]
+- action: replace
+  pattern: secret
+`))
+	assert.NoError(t, err)
+
+	// The short line is quarantined, with the same values redacted as if it had been parsed.
+	data := []byte("#Fields: date time cs-uri-query cs(Referer)\n2020-06-18	03:38:13	token=abc	https://example.com/secret\n2020-06-18	03:38:14	token=abc\n")
+
+	var quarantined []string
+
+	processor := mock.NewProcessor()
+	err = Process(data, Options{
+		Unparseable: UnparseableQuarantine,
+		Redactors:   []Redactor{rules.For("/cloudfront/example", "")},
+		Quarantine: func(line string, number int, reason error) error {
+			quarantined = append(quarantined, line)
+			return nil
+		},
+	}, processor.Process)
+	assert.NoError(t, err)
+	assert.Equal(t, "token=REDACTED	https://example.com/REDACTED", *processor.GetEvents()[0].Message)
+	assert.Equal(t, []string{"2020-06-18	03:38:14	token=REDACTED"}, quarantined)
+}
+
+func TestProcess_Decode(t *testing.T) {
+	rules, err := redact.Parse([]byte(`
+- action: mask
+  parameters: [token]
+`))
+	assert.NoError(t, err)
+
+	data := []byte("#Fields: date time cs(User-Agent) cs-uri-query\n2020-06-18	03:38:13	curl/7.64.1%20(test)	q=red%20shoes&token=abc\n")
+
+	processor := mock.NewProcessor()
+	err = Process(data, Options{
+		Output:    OutputJSON,
+		Decode:    true,
+		Redactors: []Redactor{rules.For("/cloudfront/example", "")},
+	}, processor.Process)
+	assert.NoError(t, err)
+	// The raw values are pushed next to the decoded values, redacted in the same way.
+	assert.Equal(t, `{"user_agent":"curl/7.64.1 (test)","user_agent_raw":"curl/7.64.1%20(test)","uri_query":"q=red shoes\u0026token=REDACTED","uri_query_raw":"q=red%20shoes\u0026token=REDACTED"}`, *processor.GetEvents()[0].Message)
+
+	// The raw values follow their decoded field through the projection.
+	processor = mock.NewProcessor()
+	err = Process(data, Options{Output: OutputJSON, Decode: true, Projection: Projection{Exclude: []string{"cs(User-Agent)", "uri_query_raw"}}}, processor.Process)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"uri_query":"q=red shoes\u0026token=abc"}`, *processor.GetEvents()[0].Message)
+
+	// Text output keeps the columns of the original line.
+	processor = mock.NewProcessor()
+	err = Process(data, Options{Output: OutputText, Decode: true}, processor.Process)
+	assert.NoError(t, err)
+	assert.Equal(t, "curl/7.64.1 (test)	q=red shoes&token=abc", *processor.GetEvents()[0].Message)
+}
+
+// statusFilter keeps records with the status.
+type statusFilter int64
+
+// Keep implements the interface.
+func (f statusFilter) Keep(record *parser.AccessLogRecord) (bool, error) {
+	return *record.Status == int64(f), nil
+}
+
+func TestProcess_Filter(t *testing.T) {
+	data := []byte("#Fields: date time sc-status\n2020-06-18	03:38:13	200\n2020-06-18	03:38:14	503\n")
+
+	processor := mock.NewProcessor()
+	err := Process(data, Options{Filters: []Filter{statusFilter(503)}}, processor.Process)
+	assert.NoError(t, err)
+	assert.Len(t, processor.GetEvents(), 1)
+	assert.Equal(t, "503", *processor.GetEvents()[0].Message)
+}
+
+// statusObserver records the status of each record it observes.
+type statusObserver []int64
+
+// Observe implements the interface.
+func (o *statusObserver) Observe(record *parser.AccessLogRecord) {
+	*o = append(*o, *record.Status)
+}
+
+func TestProcess_Observer(t *testing.T) {
+	data := []byte("#Fields: date time sc-status\n2020-06-18	03:38:13	200\n2020-06-18	03:38:14	503\n")
+
+	// Only the records which are kept by the filters are observed.
+	var observer statusObserver
+
+	processor := mock.NewProcessor()
+	err := Process(data, Options{Filters: []Filter{statusFilter(503)}, Observers: []Observer{&observer}}, processor.Process)
+	assert.NoError(t, err)
+	assert.Len(t, processor.GetEvents(), 1)
+	assert.Equal(t, statusObserver{503}, observer)
+}
+
+func TestProcess_S3(t *testing.T) {
+	// S3 server access logs are not compressed.
+	data := []byte(`owner bucket [06/Feb/2019:00:00:38 +0000] 192.0.2.3 - 3E57427F3EXAMPLE REST.GET.OBJECT key "GET /bucket/key HTTP/1.1" 200 - 113 113 7 6 "-" "curl/7.68.0" -
+owner bucket [06/Feb/2019:00:00:39 +0000] 192.0.2.3 - 3E57427F3EXAMPLE REST.GET.OBJECT missing "GET /bucket/missing HTTP/1.1" 404 NoSuchKey 243 - 5 - "-" "curl/7.68.0" -
+`)
+
+	processor := mock.NewProcessor()
+	err := Process(data, Options{Format: format.S3{}, Output: OutputJSON}, processor.Process)
+	assert.NoError(t, err)
+	assert.Len(t, processor.GetEvents(), 2)
+	assert.Equal(t, int64(1549411239000), *processor.GetEvents()[1].Timestamp)
+
+	var message map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(*processor.GetEvents()[1].Message), &message))
+	assert.Equal(t, "NoSuchKey", message["error_code"])
+	assert.Equal(t, "/bucket/missing", message["uri_stem"])
+}
+
+func TestProcess_WAF(t *testing.T) {
+	var data bytes.Buffer
+
+	writer := gzip.NewWriter(&data)
+	_, err := writer.Write([]byte(`{"timestamp":1576280412771,"action":"BLOCK","terminatingRuleId":"RateLimit","httpRequest":{"clientIp":"192.0.2.10","uri":"/login"}}
+{"timestamp":1576280412800,"action":"ALLOW","terminatingRuleId":"Default_Action","httpRequest":{"clientIp":"192.0.2.11","uri":"/"}}
+`))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	processor := mock.NewProcessor()
+	err = Process(data.Bytes(), Options{
+		Format:     format.WAF{},
+		Output:     OutputJSON,
+		Projection: Projection{Include: []string{"terminatingRuleId", "action", "c-ip"}},
+	}, processor.Process)
+	assert.NoError(t, err)
+	assert.Len(t, processor.GetEvents(), 2)
+	assert.Equal(t, int64(1576280412771), *processor.GetEvents()[0].Timestamp)
+	assert.Equal(t, `{"action":"BLOCK","terminatingRuleId":"RateLimit","client_ip":"192.0.2.10"}`, *processor.GetEvents()[0].Message)
+}
+
+func TestDetectFormat(t *testing.T) {
+	contents, err := ioutil.ReadFile("testdata/test-logs.gz")
+	assert.NoError(t, err)
+
+	// The first line of compressed objects is used.
+	logFormat, ok := DetectFormat("logs/access.log.gz", contents, format.WAF{})
+	assert.True(t, ok)
+	assert.Equal(t, format.CloudFront{}, logFormat)
+
+	logFormat, ok = DetectFormat("logs/access.log", []byte(`{"timestamp":1576280412771,"webaclId":"arn:aws:wafv2:us-east-1:123456789012:global/webacl/my-web-acl/a1b2c3d4"}`+"\n"), nil)
+	assert.True(t, ok)
+	assert.Equal(t, format.WAF{}, logFormat)
+
+	logFormat, ok = DetectFormat("logs/access.parquet", []byte("PAR1"), format.WAF{})
+	assert.True(t, ok)
+	assert.Equal(t, format.CloudFront{}, logFormat)
+
+	// The fallback is used if the format can't be detected.
+	logFormat, ok = DetectFormat("logs/access.log", []byte("not a log line\n"), format.S3{})
+	assert.False(t, ok)
+	assert.Equal(t, format.S3{}, logFormat)
+
+	logFormat, ok = DetectFormat("logs/access.log", []byte("not a log line\n"), nil)
+	assert.False(t, ok)
+	assert.Equal(t, format.CloudFront{}, logFormat)
+}
+
+// failingReader returns an error once the contents have been read.
+type failingReader struct {
+	reader io.Reader
+}
+
+// Read implements the interface.
+func (r failingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+func TestProcessReader(t *testing.T) {
+	contents, err := ioutil.ReadFile("testdata/test-logs.gz")
+	assert.NoError(t, err)
+
+	processor := mock.NewProcessor()
+	err = ProcessReader(bytes.NewReader(contents), Options{Output: OutputText}, processor.Process)
+	assert.NoError(t, err)
+	assert.Len(t, processor.GetEvents(), 58)
+
+	// Errors reading the object are returned.
+	processor = mock.NewProcessor()
+	err = ProcessReader(failingReader{bytes.NewReader(contents)}, Options{Output: OutputText}, processor.Process)
+	assert.ErrorContains(t, err, "connection reset")
+
+	err = ProcessReader(failingReader{strings.NewReader("")}, Options{Output: OutputText}, processor.Process)
+	assert.ErrorContains(t, err, "error reading object: connection reset")
+
+	// Empty objects have no events.
+	processor = mock.NewProcessor()
+	err = ProcessReader(strings.NewReader(""), Options{Output: OutputText}, processor.Process)
+	assert.NoError(t, err)
+	assert.Empty(t, processor.GetEvents())
+}
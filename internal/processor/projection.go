@@ -0,0 +1,57 @@
+package processor
+
+import (
+	"strings"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// Projection selects the fields of each record which are pushed.
+type Projection struct {
+	// Include only these fields, or all fields if empty.
+	Include []string
+	// Exclude these fields.
+	Exclude []string
+}
+
+// Enabled returns true if the projection removes any fields.
+func (p Projection) Enabled() bool {
+	return len(p.Include) > 0 || len(p.Exclude) > 0
+}
+
+// Apply the projection to the record, removing the fields which aren't selected.
+func (p Projection) Apply(record *parser.AccessLogRecord) {
+	// Copy the fields as they are modified while removing.
+	fields := append([]string{}, record.Fields()...)
+
+	for _, name := range fields {
+		if !p.selects(name) {
+			record.Remove(name)
+		}
+	}
+}
+
+// selects returns true if the field is selected by the projection. Fields can be named as they appear in the header or
+// by their JSON key.
+func (p Projection) selects(name string) bool {
+	if len(p.Include) > 0 && !matchesField(p.Include, name) {
+		return false
+	}
+
+	return !matchesField(p.Exclude, name)
+}
+
+// matchesField returns true if the list contains the field or its JSON key. The raw value of a decoded field is matched
+// by the decoded field as well, eg. user_agent_raw by cs(User-Agent).
+func matchesField(list []string, name string) bool {
+	key := parser.Key(name)
+	decoded, raw := strings.CutSuffix(key, parser.RawSuffix)
+
+	for _, item := range list {
+		if item == name || item == key || raw && parser.Key(item) == decoded {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/processor/mock"
+)
+
+func TestProjection_Apply(t *testing.T) {
+	fields := []string{"date", "time", "x-edge-location", "sc-status", "x-edge-request-id", "fle-status", "ssl-cipher"}
+	line := "2020-06-18	03:38:13	SYD4-C2	200	oe49fbR4FcmNWieL3CVBnkQFZiNls0O9Zg24IfUYPWOXMX36hqQI4g==	-	ECDHE-RSA-AES128-GCM-SHA256"
+
+	tests := []struct {
+		projection Projection
+		expected   string
+	}{
+		{
+			projection: Projection{Exclude: []string{"fle-status", "ssl-cipher"}},
+			expected:   "SYD4-C2	200	oe49fbR4FcmNWieL3CVBnkQFZiNls0O9Zg24IfUYPWOXMX36hqQI4g==",
+		},
+		{
+			// Fields can be named by their JSON key.
+			projection: Projection{Include: []string{"edge_location", "sc-status"}},
+			expected:   "SYD4-C2	200",
+		},
+		{
+			projection: Projection{Include: []string{"x-edge-location", "sc-status", "x-edge-request-id"}, Exclude: []string{"edge_request_id"}},
+			expected:   "SYD4-C2	200",
+		},
+	}
+
+	for _, test := range tests {
+		record, err := parser.ParseRecord(fields, line)
+		assert.NoError(t, err)
+
+		test.projection.Apply(record)
+		assert.Equal(t, test.expected, record.Message())
+	}
+
+	assert.False(t, Projection{}.Enabled())
+}
+
+func TestProcess_Projection(t *testing.T) {
+	data := []byte("#Fields: date time x-edge-location sc-status fle-status ssl-cipher\n2020-06-18	03:38:13	SYD4-C2	200	-	ECDHE-RSA-AES128-GCM-SHA256\n")
+
+	var before, after int
+
+	options := Options{
+		Output:     OutputJSON,
+		Projection: Projection{Exclude: []string{"fle-status", "ssl-cipher"}},
+		Projected: func(b, a int) {
+			before += b
+			after += a
+		},
+	}
+
+	processor := mock.NewProcessor()
+	err := Process(data, options, processor.Process)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"edge_location":"SYD4-C2","status":200}`, *processor.GetEvents()[0].Message)
+	assert.Equal(t, len(`{"edge_location":"SYD4-C2","status":200,"fle_status":null,"ssl_cipher":"ECDHE-RSA-AES128-GCM-SHA256"}`), before)
+	assert.Equal(t, len(`{"edge_location":"SYD4-C2","status":200}`), after)
+}
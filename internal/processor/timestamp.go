@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// TimestampPolicy determines the timestamp of each log event.
+type TimestampPolicy string
+
+const (
+	// TimestampEnd uses the time the request ended, as logged by CloudFront.
+	TimestampEnd TimestampPolicy = "end"
+	// TimestampStart uses the time the request started, calculated from the time taken with millisecond precision.
+	TimestampStart TimestampPolicy = "start"
+	// TimestampIngestion uses the time the line was processed.
+	TimestampIngestion TimestampPolicy = "ingestion"
+)
+
+// Validate the timestamp policy.
+func (p TimestampPolicy) Validate() error {
+	switch p {
+	case TimestampEnd, TimestampStart, TimestampIngestion:
+		return nil
+	}
+
+	return fmt.Errorf("unsupported timestamp policy: %s", p)
+}
+
+// Timestamp of the record according to the policy.
+func (p TimestampPolicy) Timestamp(record *parser.AccessLogRecord) time.Time {
+	switch p {
+	case TimestampStart:
+		if record.TimeTaken == nil {
+			return record.Timestamp
+		}
+		taken := time.Duration(math.Round(*record.TimeTaken*1000)) * time.Millisecond
+		return record.Timestamp.Add(-taken)
+	case TimestampIngestion:
+		return time.Now()
+	default:
+		return record.Timestamp
+	}
+}
+
+// UnparseablePolicy determines what happens to lines which can't be parsed, and so have no timestamp.
+type UnparseablePolicy string
+
+const (
+	// UnparseableLastModified pushes the line with the time the object was last modified.
+	UnparseableLastModified UnparseablePolicy = "last-modified"
+	// UnparseableDrop discards the line.
+	UnparseableDrop UnparseablePolicy = "drop"
+	// UnparseableQuarantine hands the line to the quarantine.
+	UnparseableQuarantine UnparseablePolicy = "quarantine"
+)
+
+// Validate the unparseable policy.
+func (p UnparseablePolicy) Validate() error {
+	switch p {
+	case UnparseableLastModified, UnparseableDrop, UnparseableQuarantine:
+		return nil
+	}
+
+	return fmt.Errorf("unsupported unparseable policy: %s", p)
+}
@@ -0,0 +1,75 @@
+package processor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/processor/mock"
+)
+
+func TestTimestampPolicy_Timestamp(t *testing.T) {
+	record, err := parser.ParseRecord([]string{"date", "time", "time-taken"}, "2020-06-18	03:38:13	0.301")
+	assert.NoError(t, err)
+
+	end := time.Date(2020, 6, 18, 3, 38, 13, 0, time.UTC)
+	assert.Equal(t, end, TimestampEnd.Timestamp(record))
+	assert.Equal(t, end.Add(-301*time.Millisecond), TimestampStart.Timestamp(record))
+	assert.WithinDuration(t, time.Now(), TimestampIngestion.Timestamp(record), time.Minute)
+
+	assert.Error(t, TimestampPolicy("middle").Validate())
+	assert.Error(t, UnparseablePolicy("ignore").Validate())
+}
+
+func TestProcess_Unparseable(t *testing.T) {
+	data := []byte("#Fields: date time sc-status\n2020-06-18	03:38:13	200\nnot a log line\n")
+	lastModified := time.Date(2020, 6, 18, 4, 0, 0, 0, time.UTC)
+
+	// Unparseable lines are pushed with the last modified time.
+	processor := mock.NewProcessor()
+	err := Process(data, Options{Unparseable: UnparseableLastModified, LastModified: lastModified}, processor.Process)
+	assert.NoError(t, err)
+	assert.Len(t, processor.GetEvents(), 2)
+	assert.Equal(t, lastModified.UnixMilli(), *processor.GetEvents()[1].Timestamp)
+	assert.Equal(t, "not a log line", *processor.GetEvents()[1].Message)
+
+	// Unparseable lines are dropped.
+	processor = mock.NewProcessor()
+	err = Process(data, Options{Unparseable: UnparseableDrop}, processor.Process)
+	assert.NoError(t, err)
+	assert.Len(t, processor.GetEvents(), 1)
+
+	// Unparseable lines are quarantined.
+	var quarantined []string
+	processor = mock.NewProcessor()
+	err = Process(data, Options{
+		Unparseable: UnparseableQuarantine,
+		Quarantine: func(line string, number int, reason error) error {
+			assert.Equal(t, 3, number)
+			assert.True(t, errors.Is(reason, parser.ErrShortLine))
+			quarantined = append(quarantined, line)
+			return nil
+		},
+	}, processor.Process)
+	assert.NoError(t, err)
+	assert.Len(t, processor.GetEvents(), 1)
+	assert.Equal(t, []string{"not a log line"}, quarantined)
+}
+
+func TestProcess_TimestampProjection(t *testing.T) {
+	data := []byte("#Fields: date time sc-status time-taken\n2020-06-18	03:38:13	200	10.000\n")
+
+	// The start of the request is calculated before time-taken is excluded.
+	processor := mock.NewProcessor()
+	err := Process(data, Options{
+		Timestamp:  TimestampStart,
+		Projection: Projection{Exclude: []string{"time-taken"}},
+	}, processor.Process)
+	assert.NoError(t, err)
+	assert.Len(t, processor.GetEvents(), 1)
+	assert.Equal(t, time.Date(2020, 6, 18, 3, 38, 3, 0, time.UTC).UnixMilli(), *processor.GetEvents()[0].Timestamp)
+	assert.Equal(t, "200", *processor.GetEvents()[0].Message)
+}
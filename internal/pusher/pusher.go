@@ -16,6 +16,13 @@ import (
 	"github.com/skpr/cloudfront-cloudwatchlogs/internal/utils"
 )
 
+const (
+	// MaxPayloadSize is the largest batch of events accepted by PutLogEvents, in bytes.
+	MaxPayloadSize = 1048576
+	// eventOverhead is the amount of bytes counted for each event on top of its message.
+	eventOverhead = 26
+)
+
 // BatchLogPusher cwLogsClient for handling log events.
 // @TODO convert into lib reused by fluentbit-cloudwatchlogs
 type BatchLogPusher struct {
@@ -54,7 +61,8 @@ func (p *BatchLogPusher) Add(ctx context.Context, event awstypes.InputLogEvent)
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
-	if len(p.input.LogEvents) >= p.batchSize {
+	// Events are flushed once there are enough of them, or before they would be too large to push together.
+	if len(p.input.LogEvents) >= p.batchSize || p.exceedsPayload(event) {
 		err := p.Flush(ctx)
 		if err != nil {
 			return err
@@ -142,10 +150,16 @@ func (p *BatchLogPusher) updateEventsSize(event awstypes.InputLogEvent) {
 // calculatePayloadSize calculates the approximate payload size.
 func (p *BatchLogPusher) calculatePayloadSize() int64 {
 	// size is calculated as the sum of all event messages in UTF-8, plus 26 bytes for each log event.
-	bytesOverhead := (len(p.input.LogEvents) + 1) * 26
+	bytesOverhead := (len(p.input.LogEvents) + 1) * eventOverhead
 	return p.eventsSize + int64(bytesOverhead)
 }
 
+// exceedsPayload returns true if adding the event would make the batch larger than PutLogEvents accepts.
+func (p *BatchLogPusher) exceedsPayload(event awstypes.InputLogEvent) bool {
+	size := p.eventsSize + int64(len(aws.ToString(event.Message))) + int64((len(p.input.LogEvents)+1)*eventOverhead)
+	return size > MaxPayloadSize
+}
+
 // clearEvents clears the events buffer.
 func (p *BatchLogPusher) clearEvents() {
 	p.input.LogEvents = []awstypes.InputLogEvent{}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"os"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -37,6 +38,28 @@ func TestBatchLogPusher_Add(t *testing.T) {
 	assert.Len(t, logPusher.input.LogEvents, 1)
 }
 
+func TestBatchLogPusher_AddLarge(t *testing.T) {
+	ctx := context.TODO()
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	logPusher := NewBatchLogPusher(ctx, logger, mock.NewCloudwatchLogs(), "foo", "bar", 1024)
+
+	// 1024 events of 1 KB are larger than PutLogEvents accepts, so they are pushed before the batch is full.
+	message := strings.Repeat("a", 1099)
+
+	for i := 0; i < 1024; i++ {
+		err := logPusher.Add(ctx, types.InputLogEvent{
+			Message:   aws.String(message),
+			Timestamp: aws.Int64(time.Now().UnixMilli()),
+		})
+		assert.NoError(t, err)
+		// The payload size allows for one more event.
+		assert.LessOrEqual(t, logPusher.calculatePayloadSize()-eventOverhead, int64(MaxPayloadSize))
+	}
+
+	// The first batch is as large as it can be.
+	assert.Len(t, logPusher.input.LogEvents, 1024-MaxPayloadSize/(1099+eventOverhead))
+}
+
 func TestBatchLogPusher_AddMany(t *testing.T) {
 	t.Skipf("Skipping performance test")
 	PrintMemUsage()
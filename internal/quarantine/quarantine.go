@@ -0,0 +1,133 @@
+package quarantine
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// Reason is a machine-readable code describing why a line was quarantined.
+type Reason string
+
+const (
+	// ReasonShortLine is used for lines with fewer columns than the header, eg. truncated lines.
+	ReasonShortLine Reason = "short_line"
+	// ReasonColumnCount is used for lines with more columns than the header.
+	ReasonColumnCount Reason = "column_count_mismatch"
+	// ReasonBadDate is used for lines where the date or time can't be parsed.
+	ReasonBadDate Reason = "bad_date"
+	// ReasonBadValue is used for lines where a typed column can't be parsed.
+	ReasonBadValue Reason = "bad_value"
+	// ReasonBadJSON is used for lines which are not a JSON object.
+	ReasonBadJSON Reason = "bad_json"
+	// ReasonUnknown is used for any other error.
+	ReasonUnknown Reason = "unknown"
+)
+
+// ReasonFor returns the reason for the error returned when parsing a line.
+func ReasonFor(err error) Reason {
+	switch {
+	case errors.Is(err, parser.ErrShortLine):
+		return ReasonShortLine
+	case errors.Is(err, parser.ErrColumnCount):
+		return ReasonColumnCount
+	case errors.Is(err, parser.ErrDate):
+		return ReasonBadDate
+	case errors.Is(err, parser.ErrValue):
+		return ReasonBadValue
+	case errors.Is(err, parser.ErrJSON):
+		return ReasonBadJSON
+	default:
+		return ReasonUnknown
+	}
+}
+
+// Entry is a quarantined line.
+type Entry struct {
+	// Bucket the line was read from.
+	Bucket string `json:"bucket"`
+	// Key of the object the line was read from.
+	Key string `json:"key"`
+	// Line number within the object.
+	Line int `json:"line"`
+	// Reason the line was quarantined.
+	Reason Reason `json:"reason"`
+	// Error describing the reason in more detail.
+	Error string `json:"error"`
+	// Message is the line which was quarantined.
+	Message string `json:"message"`
+}
+
+// NewEntry creates an entry for a line which couldn't be parsed.
+func NewEntry(bucket, key string, line int, message string, err error) Entry {
+	return Entry{
+		Bucket:  bucket,
+		Key:     key,
+		Line:    line,
+		Reason:  ReasonFor(err),
+		Error:   err.Error(),
+		Message: message,
+	}
+}
+
+// Destination receives quarantined lines.
+type Destination interface {
+	// Add an entry to the destination.
+	Add(ctx context.Context, entry Entry) error
+	// Flush entries which have been added.
+	Flush(ctx context.Context) error
+}
+
+// Counter counts the entries added to a destination by reason.
+type Counter struct {
+	Destination
+	counts map[Reason]int
+	lock   sync.Mutex
+}
+
+// NewCounter creates a counter for the destination.
+func NewCounter(destination Destination) *Counter {
+	return &Counter{
+		Destination: destination,
+		counts:      make(map[Reason]int),
+	}
+}
+
+// Add an entry to the destination and count it.
+func (c *Counter) Add(ctx context.Context, entry Entry) error {
+	c.lock.Lock()
+	c.counts[entry.Reason]++
+	c.lock.Unlock()
+
+	return c.Destination.Add(ctx, entry)
+}
+
+// Total amount of entries added.
+func (c *Counter) Total() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var total int
+
+	for _, count := range c.counts {
+		total += count
+	}
+
+	return total
+}
+
+// Counts of the entries added by reason.
+func (c *Counter) Counts() map[Reason]int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	counts := make(map[Reason]int, len(c.counts))
+
+	for reason, count := range c.counts {
+		counts[reason] = count
+	}
+
+	return counts
+}
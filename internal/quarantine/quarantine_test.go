@@ -0,0 +1,74 @@
+package quarantine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// mockPutObject records the objects which are put.
+type mockPutObject struct {
+	objects map[string]string
+}
+
+// PutObject implements the interface.
+func (m *mockPutObject) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(options *s3.Options)) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.objects[*params.Bucket+"/"+*params.Key] = string(body)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestReasonFor(t *testing.T) {
+	_, err := parser.ParseRecord(parser.DefaultFields, "2020-06-18	03:38:13	SYD4-C2")
+	assert.Equal(t, ReasonShortLine, ReasonFor(err))
+
+	_, err = parser.ParseRecord([]string{"date"}, "2020-06-18	03:38:13")
+	assert.Equal(t, ReasonColumnCount, ReasonFor(err))
+
+	_, err = parser.ParseRecord([]string{"date", "time"}, "2020-06-18	nope")
+	assert.Equal(t, ReasonBadDate, ReasonFor(err))
+
+	_, err = parser.ParseJSONRecord([]byte("{"))
+	assert.Equal(t, ReasonBadJSON, ReasonFor(err))
+
+	assert.Equal(t, ReasonUnknown, ReasonFor(errors.New("something else")))
+}
+
+func TestCounter_S3Destination(t *testing.T) {
+	ctx := context.TODO()
+	client := &mockPutObject{objects: map[string]string{}}
+	counter := NewCounter(NewS3Destination(client, "bucket", "quarantine/logs/E38J4Y0L8GXH9D.2020-06-08-07.d51ccc94.gz.ndjson"))
+
+	// Nothing is written when nothing has been quarantined.
+	assert.NoError(t, counter.Flush(ctx))
+	assert.Empty(t, client.objects)
+
+	assert.NoError(t, counter.Add(ctx, NewEntry("logs", "E38J4Y0L8GXH9D.2020-06-08-07.d51ccc94.gz", 3, "2020-06-18", parser.ErrShortLine)))
+	assert.NoError(t, counter.Add(ctx, NewEntry("logs", "E38J4Y0L8GXH9D.2020-06-08-07.d51ccc94.gz", 7, "2020-06-18", parser.ErrShortLine)))
+	assert.NoError(t, counter.Add(ctx, NewEntry("logs", "E38J4Y0L8GXH9D.2020-06-08-07.d51ccc94.gz", 9, "nope", parser.ErrDate)))
+	assert.NoError(t, counter.Flush(ctx))
+
+	assert.Equal(t, 3, counter.Total())
+	assert.Equal(t, map[Reason]int{ReasonShortLine: 2, ReasonBadDate: 1}, counter.Counts())
+
+	lines := strings.Split(strings.TrimSpace(client.objects["bucket/quarantine/logs/E38J4Y0L8GXH9D.2020-06-08-07.d51ccc94.gz.ndjson"]), "\n")
+	assert.Len(t, lines, 3)
+
+	var entry Entry
+	assert.NoError(t, json.Unmarshal([]byte(lines[2]), &entry))
+	assert.Equal(t, "logs", entry.Bucket)
+	assert.Equal(t, 9, entry.Line)
+	assert.Equal(t, ReasonBadDate, entry.Reason)
+	assert.Equal(t, "nope", entry.Message)
+}
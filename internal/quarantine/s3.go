@@ -0,0 +1,65 @@
+package quarantine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// PutObjectAPIClient is the s3 client used to write quarantined lines.
+type PutObjectAPIClient interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(options *s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// S3Destination writes quarantined lines to an s3 object as newline delimited JSON.
+type S3Destination struct {
+	client PutObjectAPIClient
+	bucket string
+	key    string
+	buf    bytes.Buffer
+	lock   sync.Mutex
+}
+
+// NewS3Destination creates a destination which writes to the s3 object when flushed.
+func NewS3Destination(client PutObjectAPIClient, bucket, key string) *S3Destination {
+	return &S3Destination{
+		client: client,
+		bucket: bucket,
+		key:    key,
+	}
+}
+
+// Add the entry to the object.
+func (d *S3Destination) Add(ctx context.Context, entry Entry) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return json.NewEncoder(&d.buf).Encode(entry)
+}
+
+// Flush the entries to the object, if there are any.
+func (d *S3Destination) Flush(ctx context.Context) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.buf.Len() == 0 {
+		return nil
+	}
+
+	_, err := d.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(d.bucket),
+		Key:         aws.String(d.key),
+		Body:        bytes.NewReader(d.buf.Bytes()),
+		ContentType: aws.String("application/x-ndjson"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write quarantine %s to %s: %w", d.key, d.bucket, err)
+	}
+
+	return nil
+}
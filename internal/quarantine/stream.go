@@ -0,0 +1,42 @@
+package quarantine
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/pusher"
+)
+
+// StreamDestination pushes quarantined lines to a CloudWatch Logs stream.
+type StreamDestination struct {
+	pusher *pusher.BatchLogPusher
+}
+
+// NewStreamDestination creates a destination which pushes to the log stream of the pusher.
+func NewStreamDestination(pusher *pusher.BatchLogPusher) *StreamDestination {
+	return &StreamDestination{
+		pusher: pusher,
+	}
+}
+
+// Add the entry as a JSON log event.
+func (d *StreamDestination) Add(ctx context.Context, entry Entry) error {
+	message, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return d.pusher.Add(ctx, awstypes.InputLogEvent{
+		Message:   aws.String(string(message)),
+		Timestamp: aws.Int64(time.Now().UnixMilli()),
+	})
+}
+
+// Flush the log events to the stream.
+func (d *StreamDestination) Flush(ctx context.Context) error {
+	return d.pusher.Flush(ctx)
+}
@@ -0,0 +1,191 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// Mode of anonymising client IPs.
+type Mode string
+
+const (
+	// ModeNone keeps client IPs as they are.
+	ModeNone Mode = "none"
+	// ModeTruncate keeps the /24 network of IPv4 addresses and the /48 network of IPv6 addresses.
+	ModeTruncate Mode = "truncate"
+	// ModeHMAC replaces addresses with a keyed hash, so requests from the same client can be correlated.
+	ModeHMAC Mode = "hmac"
+	// ModeDrop removes the fields.
+	ModeDrop Mode = "drop"
+)
+
+// Validate the mode.
+func (m Mode) Validate() error {
+	switch m {
+	case ModeNone, ModeTruncate, ModeHMAC, ModeDrop:
+		return nil
+	}
+
+	return fmt.Errorf("unsupported anonymisation mode: %s", m)
+}
+
+var (
+	// ipFields contain client IPs.
+	ipFields = []string{"c-ip", "x-forwarded-for"}
+	// ipv4Mask is the network of IPv4 addresses which is kept.
+	ipv4Mask = net.CIDRMask(24, 32)
+	// ipv6Mask is the network of IPv6 addresses which is kept.
+	ipv6Mask = net.CIDRMask(48, 128)
+)
+
+// hashLength is the amount of bytes of the hash which are kept.
+const hashLength = 16
+
+// Anonymiser anonymises the client IPs of records.
+type Anonymiser struct {
+	mode Mode
+	key  []byte
+}
+
+// NewAnonymiser creates an anonymiser, the key is required by the hmac mode.
+func NewAnonymiser(mode Mode, key []byte) (*Anonymiser, error) {
+	if err := mode.Validate(); err != nil {
+		return nil, err
+	}
+
+	if mode == ModeHMAC && len(key) == 0 {
+		return nil, fmt.Errorf("a key is required to anonymise client IPs with a hmac")
+	}
+
+	return &Anonymiser{
+		mode: mode,
+		key:  key,
+	}, nil
+}
+
+// Redact the client IPs of the record.
+func (a *Anonymiser) Redact(record *parser.AccessLogRecord) error {
+	if a.mode == ModeNone {
+		return nil
+	}
+
+	for _, name := range ipFields {
+		if a.mode == ModeDrop {
+			record.Remove(name)
+			continue
+		}
+
+		value := record.Get(name)
+		if value == parser.Empty {
+			continue
+		}
+
+		if err := record.Set(name, a.anonymiseList(value)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RedactLine anonymises anything which looks like an IP address in a line which couldn't be parsed.
+func (a *Anonymiser) RedactLine(line string) string {
+	if a.mode == ModeNone {
+		return line
+	}
+
+	var b strings.Builder
+
+	for len(line) > 0 {
+		end := strings.IndexAny(line, "\t ,;\"'")
+		if end < 0 {
+			end = len(line)
+		}
+
+		b.WriteString(a.anonymiseToken(line[:end]))
+
+		if end < len(line) {
+			b.WriteByte(line[end])
+			end++
+		}
+
+		line = line[end:]
+	}
+
+	return b.String()
+}
+
+// anonymiseToken anonymises the token if it is an IP address, which may be followed by an encoded space.
+func (a *Anonymiser) anonymiseToken(token string) string {
+	address, suffix := token, ""
+	if strings.HasSuffix(address, "%20") {
+		address, suffix = strings.TrimSuffix(address, "%20"), "%20"
+	}
+
+	if strings.HasPrefix(address, "%20") {
+		return "%20" + a.anonymiseToken(strings.TrimPrefix(address, "%20")) + suffix
+	}
+
+	if net.ParseIP(address) == nil {
+		return token
+	}
+
+	anonymised := a.Anonymise(address)
+	if anonymised == "" {
+		anonymised = parser.Empty
+	}
+
+	return anonymised + suffix
+}
+
+// anonymiseList anonymises each address of a comma separated list, eg. x-forwarded-for.
+func (a *Anonymiser) anonymiseList(value string) string {
+	addresses := strings.Split(value, ",")
+
+	for i, address := range addresses {
+		// Addresses are separated by a comma and a space, which may be encoded.
+		trimmed := strings.TrimLeft(address, " ")
+		trimmed = strings.TrimPrefix(trimmed, "%20")
+		padding := address[:len(address)-len(trimmed)]
+
+		addresses[i] = padding + a.Anonymise(trimmed)
+	}
+
+	return strings.Join(addresses, ",")
+}
+
+// Anonymise an address according to the mode.
+func (a *Anonymiser) Anonymise(address string) string {
+	switch a.mode {
+	case ModeDrop:
+		return ""
+	case ModeHMAC:
+		mac := hmac.New(sha256.New, a.key)
+		mac.Write([]byte(address))
+		return hex.EncodeToString(mac.Sum(nil)[:hashLength])
+	case ModeTruncate:
+		return truncate(address)
+	default:
+		return address
+	}
+}
+
+// truncate the address to its network, or return it as it is if it isn't an IP address.
+func truncate(address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return address
+	}
+
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4.Mask(ipv4Mask).String()
+	}
+
+	return ip.Mask(ipv6Mask).String()
+}
@@ -0,0 +1,92 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/format"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+func TestAnonymiser_Anonymise(t *testing.T) {
+	anonymiser, err := NewAnonymiser(ModeTruncate, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.0", anonymiser.Anonymise("192.0.2.123"))
+	assert.Equal(t, "2001:db8:1::", anonymiser.Anonymise("2001:db8:1:2:3:4:5:6"))
+	assert.Equal(t, "unknown", anonymiser.Anonymise("unknown"))
+
+	anonymiser, err = NewAnonymiser(ModeHMAC, []byte("secret"))
+	assert.NoError(t, err)
+	hashed := anonymiser.Anonymise("192.0.2.123")
+	assert.Len(t, hashed, hashLength*2)
+	// The same client can be correlated, but different clients can't.
+	assert.Equal(t, hashed, anonymiser.Anonymise("192.0.2.123"))
+	assert.NotEqual(t, hashed, anonymiser.Anonymise("192.0.2.124"))
+
+	// A different key gives a different hash.
+	anonymiser, err = NewAnonymiser(ModeHMAC, []byte("rotated"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, hashed, anonymiser.Anonymise("192.0.2.123"))
+
+	_, err = NewAnonymiser(ModeHMAC, nil)
+	assert.Error(t, err)
+
+	_, err = NewAnonymiser(Mode("scramble"), nil)
+	assert.Error(t, err)
+}
+
+func TestAnonymiser_Redact(t *testing.T) {
+	fields := []string{"date", "time", "c-ip", "sc-status", "x-forwarded-for"}
+	line := "2020-06-18	03:38:13	192.0.2.123	200	198.51.100.7,%202001:db8:1:2::1"
+
+	tests := []struct {
+		mode     Mode
+		expected string
+	}{
+		{mode: ModeNone, expected: "192.0.2.123	200	198.51.100.7,%202001:db8:1:2::1"},
+		{mode: ModeTruncate, expected: "192.0.2.0	200	198.51.100.0,%202001:db8:1::"},
+		{mode: ModeDrop, expected: "200"},
+	}
+
+	for _, test := range tests {
+		record, err := parser.ParseRecord(fields, line)
+		assert.NoError(t, err)
+
+		anonymiser, err := NewAnonymiser(test.mode, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, anonymiser.Redact(record))
+		assert.Equal(t, test.expected, record.Message(), test.mode)
+	}
+
+	// Records without client IPs are left alone.
+	record, err := parser.ParseRecord([]string{"date", "time", "c-ip"}, "2020-06-18	03:38:13	-")
+	assert.NoError(t, err)
+
+	anonymiser, err := NewAnonymiser(ModeHMAC, []byte("secret"))
+	assert.NoError(t, err)
+	assert.NoError(t, anonymiser.Redact(record))
+	assert.Equal(t, "", record.ClientIP)
+}
+
+func TestAnonymiser_RedactWAF(t *testing.T) {
+	// The X-Forwarded-For header of AWS WAF logs is anonymised along with the client IP.
+	record, err := format.WAF{}.NewParser().ParseLine(`{"timestamp":1576280412771,"httpRequest":{"clientIp":"192.0.2.123","headers":[{"name":"X-Forwarded-For","value":"198.51.100.7"}]}}`)
+	assert.NoError(t, err)
+
+	anonymiser, err := NewAnonymiser(ModeTruncate, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, anonymiser.Redact(record))
+	assert.Equal(t, "192.0.2.0	198.51.100.0", record.Message())
+}
+
+func TestAnonymiser_RedactLine(t *testing.T) {
+	anonymiser, err := NewAnonymiser(ModeTruncate, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "2020-06-18	03:38:13	SYD4-C2	192.0.2.0	198.51.100.0,%202001:db8:1::", anonymiser.RedactLine("2020-06-18	03:38:13	SYD4-C2	192.0.2.123	198.51.100.7,%202001:db8:1:2::1"))
+	assert.Equal(t, `{"c-ip":"192.0.2.0"}`, anonymiser.RedactLine(`{"c-ip":"192.0.2.123"}`))
+
+	anonymiser, err = NewAnonymiser(ModeDrop, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "2020-06-18	-	SYD4-C2", anonymiser.RedactLine("2020-06-18	192.0.2.123	SYD4-C2"))
+}
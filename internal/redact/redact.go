@@ -0,0 +1,374 @@
+package redact
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+// Action taken by a rule.
+type Action string
+
+const (
+	// ActionDrop removes the fields from the record.
+	ActionDrop Action = "drop"
+	// ActionMask replaces the values of named query string and cookie parameters.
+	ActionMask Action = "mask"
+	// ActionReplace replaces the matches of a regular expression.
+	ActionReplace Action = "replace"
+)
+
+// DefaultReplacement is used in place of redacted values when a rule has no replacement.
+const DefaultReplacement = "REDACTED"
+
+// defaultMaskFields are masked when a mask rule has no fields.
+var defaultMaskFields = []string{"uri_query", "cookie"}
+
+// Rule redacts the fields of records.
+type Rule struct {
+	// Name of the rule, used when counting redactions.
+	Name string `yaml:"name"`
+	// Action taken by the rule.
+	Action Action `yaml:"action"`
+	// Fields the rule applies to, named as they appear in the header or by their JSON key.
+	Fields []string `yaml:"fields"`
+	// Parameters of the query string or cookie which are masked.
+	Parameters []string `yaml:"parameters"`
+	// Pattern which is replaced.
+	Pattern string `yaml:"pattern"`
+	// Replacement for redacted values.
+	Replacement string `yaml:"replacement"`
+	// LogGroups the rule applies to, or all log groups if empty.
+	LogGroups []string `yaml:"log_groups"`
+	// Prefixes of the object keys the rule applies to, or all keys if empty.
+	Prefixes []string `yaml:"prefixes"`
+
+	// pattern is the compiled pattern.
+	pattern *regexp.Regexp
+	// parameters matches the masked parameters anywhere in a line which couldn't be parsed.
+	parameters *regexp.Regexp
+}
+
+// Rules for redacting records.
+type Rules struct {
+	rules []Rule
+}
+
+// Load the rules from a YAML file.
+func Load(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redaction rules: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// Parse the rules from YAML.
+func Parse(data []byte) (*Rules, error) {
+	var rules []Rule
+
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse redaction rules: %w", err)
+	}
+
+	for i := range rules {
+		if err := rules[i].compile(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Rules{
+		rules: rules,
+	}, nil
+}
+
+// compile validates the rule and fills in its defaults.
+func (r *Rule) compile(index int) error {
+	if r.Name == "" {
+		r.Name = fmt.Sprintf("rule-%d", index+1)
+	}
+
+	if r.Replacement == "" {
+		r.Replacement = DefaultReplacement
+	}
+
+	switch r.Action {
+	case ActionDrop:
+		if len(r.Fields) == 0 {
+			return fmt.Errorf("redaction rule %s drops no fields", r.Name)
+		}
+	case ActionMask:
+		if len(r.Parameters) == 0 {
+			return fmt.Errorf("redaction rule %s masks no parameters", r.Name)
+		}
+		if len(r.Fields) == 0 {
+			r.Fields = defaultMaskFields
+		}
+		r.parameters = parametersPattern(r.Parameters)
+	case ActionReplace:
+		if r.Pattern == "" {
+			return fmt.Errorf("redaction rule %s has no pattern", r.Name)
+		}
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("redaction rule %s has an invalid pattern: %w", r.Name, err)
+		}
+		r.pattern = pattern
+	default:
+		return fmt.Errorf("redaction rule %s has an unsupported action: %s", r.Name, r.Action)
+	}
+
+	return nil
+}
+
+// For returns a redactor with the rules which apply to the log group and object key.
+func (r *Rules) For(logGroup, key string) *Redactor {
+	redactor := &Redactor{
+		counts: make(map[string]int),
+	}
+
+	for _, rule := range r.rules {
+		if rule.applies(logGroup, key) {
+			redactor.rules = append(redactor.rules, rule)
+		}
+	}
+
+	return redactor
+}
+
+// applies returns true if the rule is scoped to the log group and object key.
+func (r Rule) applies(logGroup, key string) bool {
+	if len(r.LogGroups) > 0 && !contains(r.LogGroups, logGroup) {
+		return false
+	}
+
+	if len(r.Prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range r.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Redactor applies rules to records, counting the redactions made by each rule.
+type Redactor struct {
+	rules  []Rule
+	counts map[string]int
+}
+
+// Redact the record.
+func (r *Redactor) Redact(record *parser.AccessLogRecord) error {
+	for _, rule := range r.rules {
+		for _, name := range rule.fieldsOf(record) {
+			count, err := rule.redact(record, name)
+			if err != nil {
+				return fmt.Errorf("failed to apply redaction rule %s to %s: %w", rule.Name, name, err)
+			}
+			r.counts[rule.Name] += count
+		}
+	}
+
+	return nil
+}
+
+// RedactLine applies the rules to a line which couldn't be parsed, so its fields can't be located. Patterns are
+// replaced throughout the line and parameters are masked wherever they appear, while fields can't be dropped.
+func (r *Redactor) RedactLine(line string) string {
+	for _, rule := range r.rules {
+		var count int
+
+		line, count = rule.redactLine(line)
+		if count > 0 {
+			r.counts[rule.Name] += count
+		}
+	}
+
+	return line
+}
+
+// redactLine applies the rule to a line which couldn't be parsed, returning the amount of redactions.
+func (r Rule) redactLine(line string) (string, int) {
+	switch r.Action {
+	case ActionMask:
+		matches := r.parameters.FindAllStringSubmatchIndex(line, -1)
+		if len(matches) == 0 {
+			return line, 0
+		}
+
+		var b strings.Builder
+
+		previous := 0
+
+		for _, match := range matches {
+			// The value starts after the name and its equals sign, which are kept.
+			b.WriteString(line[previous:match[2]])
+			b.WriteString(r.Replacement)
+			previous = match[1]
+		}
+
+		b.WriteString(line[previous:])
+
+		return b.String(), len(matches)
+	case ActionReplace:
+		count := len(r.pattern.FindAllStringIndex(line, -1))
+		if count == 0 {
+			return line, 0
+		}
+		return r.pattern.ReplaceAllString(line, r.Replacement), count
+	}
+
+	return line, 0
+}
+
+// parametersPattern matches the parameters of a query string or cookie header, capturing their value.
+func parametersPattern(parameters []string) *regexp.Regexp {
+	names := make([]string, len(parameters))
+
+	for i, name := range parameters {
+		names[i] = regexp.QuoteMeta(name)
+	}
+
+	// Parameters start the line or follow a separator, which may be an encoded space.
+	return regexp.MustCompile(`(?i)(?:^|[\s?&;"',]|%20)(?:` + strings.Join(names, "|") + `)=([^\s&;"',]*)`)
+}
+
+// Counts of the redactions made by each rule.
+func (r *Redactor) Counts() map[string]int {
+	return r.counts
+}
+
+// Total amount of redactions.
+func (r *Redactor) Total() int {
+	var total int
+
+	for _, count := range r.counts {
+		total += count
+	}
+
+	return total
+}
+
+// fieldsOf returns the fields of the record which the rule applies to.
+func (r Rule) fieldsOf(record *parser.AccessLogRecord) []string {
+	var fields []string
+
+	for _, name := range record.Fields() {
+		if len(r.Fields) == 0 || contains(r.Fields, name) || contains(r.Fields, parser.Key(name)) {
+			fields = append(fields, name)
+		}
+	}
+
+	return fields
+}
+
+// redact the field of the record, returning the amount of redactions.
+func (r Rule) redact(record *parser.AccessLogRecord, name string) (int, error) {
+	if r.Action == ActionDrop {
+		record.Remove(name)
+		return 1, nil
+	}
+
+	value, ok := record.Value(name).(string)
+	if !ok || value == "" {
+		// Only strings can be redacted.
+		return 0, nil
+	}
+
+	redacted, count := r.redactValue(value, name)
+
+	if record.Decoded(name) {
+		// The raw value is pushed alongside the decoded value, so it is redacted as well. A pattern which only matches
+		// the decoded value replaces the raw value with the redacted one, so nothing is left encoded.
+		raw, rawCount := r.redactValue(record.Raw(name), name)
+		if rawCount == 0 && count > 0 {
+			raw, rawCount = redacted, count
+		}
+		if rawCount > 0 {
+			record.SetRaw(name, raw)
+		}
+	}
+
+	if count == 0 {
+		return 0, nil
+	}
+
+	return count, record.Set(name, redacted)
+}
+
+// redactValue applies a mask or replace rule to the value of a field, returning the amount of redactions.
+func (r Rule) redactValue(value, name string) (string, int) {
+	switch r.Action {
+	case ActionMask:
+		return r.mask(value, separatorOf(name))
+	case ActionReplace:
+		return r.pattern.ReplaceAllString(value, r.Replacement), len(r.pattern.FindAllStringIndex(value, -1))
+	}
+
+	return value, 0
+}
+
+// mask the values of the parameters in a query string or cookie header.
+func (r Rule) mask(value, separator string) (string, int) {
+	var count int
+
+	params := strings.Split(value, separator)
+
+	for i, param := range params {
+		// Cookies are separated by a semicolon and a space, which may be encoded.
+		trimmed := strings.TrimLeft(param, " ")
+		trimmed = strings.TrimPrefix(trimmed, "%20")
+		padding := param[:len(param)-len(trimmed)]
+
+		name, _, ok := strings.Cut(trimmed, "=")
+		if !ok || !containsFold(r.Parameters, parser.Unescape(name)) {
+			continue
+		}
+
+		params[i] = padding + name + "=" + r.Replacement
+		count++
+	}
+
+	return strings.Join(params, separator), count
+}
+
+// separatorOf returns the separator between the parameters of a field.
+func separatorOf(name string) string {
+	if parser.Key(name) == "cookie" {
+		return ";"
+	}
+
+	return "&"
+}
+
+// contains returns true if the list contains the item.
+func contains(list []string, item string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsFold returns true if the list contains the item, ignoring case.
+func containsFold(list []string, item string) bool {
+	for _, i := range list {
+		if strings.EqualFold(i, item) {
+			return true
+		}
+	}
+
+	return false
+}
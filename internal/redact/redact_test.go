@@ -0,0 +1,110 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/parser"
+)
+
+const testRules = `
+- name: cookies
+  action: mask
+  parameters: [SESSID, token]
+- name: emails
+  action: replace
+  fields: [cs-uri-query, referer]
+  pattern: '[\w.+-]+(@|%40)[\w-]+\.[\w.-]+'
+  replacement: '[email]'
+- name: forwarded
+  action: drop
+  fields: [x-forwarded-for]
+  log_groups: [/cloudfront/example]
+- name: ips
+  action: drop
+  fields: [c-ip]
+  prefixes: [logs/other/]
+`
+
+func TestRules_For(t *testing.T) {
+	rules, err := Parse([]byte(testRules))
+	assert.NoError(t, err)
+
+	record, err := parser.ParseRecord(
+		[]string{"date", "time", "c-ip", "cs(Referer)", "cs-uri-query", "cs(Cookie)", "x-forwarded-for"},
+		"2020-06-18	03:38:13	192.0.2.10	https://example.com/?from=someone%40example.com	q=shoes&token=abc123&email=someone@example.com	SESSID=abc;%20theme=dark;%20token=def	198.51.100.7",
+	)
+	assert.NoError(t, err)
+
+	redactor := rules.For("/cloudfront/example", "logs/example/E38J4Y0L8GXH9D.2020-06-08-07.d51ccc94.gz")
+	assert.NoError(t, redactor.Redact(record))
+
+	assert.Equal(t, "192.0.2.10	https://example.com/?from=[email]	q=shoes&token=REDACTED&email=[email]	SESSID=REDACTED;%20theme=dark;%20token=REDACTED", record.Message())
+	assert.Equal(t, map[string]int{"cookies": 3, "emails": 2, "forwarded": 1}, redactor.Counts())
+	assert.Equal(t, 6, redactor.Total())
+
+	// Rules are scoped to their log groups and prefixes.
+	record, err = parser.ParseRecord([]string{"date", "time", "c-ip", "x-forwarded-for"}, "2020-06-18	03:38:13	192.0.2.10	198.51.100.7")
+	assert.NoError(t, err)
+
+	redactor = rules.For("/cloudfront/other", "logs/other/E38J4Y0L8GXH9D.2020-06-08-07.d51ccc94.gz")
+	assert.NoError(t, redactor.Redact(record))
+	assert.Equal(t, "198.51.100.7", record.Message())
+	assert.Equal(t, map[string]int{"ips": 1}, redactor.Counts())
+}
+
+func TestRedactor_RedactRaw(t *testing.T) {
+	rules, err := Parse([]byte(testRules))
+	assert.NoError(t, err)
+
+	record, err := parser.ParseRecord(
+		[]string{"date", "time", "cs(Referer)", "cs-uri-query"},
+		"2020-06-18	03:38:13	https://example.com/?from=someone%2540example.com	q=red%20shoes&token=abc123",
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, record.Decode())
+
+	redactor := rules.For("/cloudfront/example", "logs/example/E38J4Y0L8GXH9D.2020-06-08-07.d51ccc94.gz")
+	assert.NoError(t, redactor.Redact(record))
+
+	// The raw values are redacted along with the decoded values, without being counted twice.
+	assert.Equal(t, "q=red%20shoes&token=REDACTED", record.Raw("cs-uri-query"))
+	// A pattern which doesn't match the raw value leaves the redacted value in its place.
+	assert.Equal(t, "https://example.com/?from=[email]", record.Raw("cs(Referer)"))
+	assert.Equal(t, map[string]int{"cookies": 1, "emails": 1}, redactor.Counts())
+}
+
+func TestRedactor_RedactLine(t *testing.T) {
+	rules, err := Parse([]byte(testRules))
+	assert.NoError(t, err)
+
+	// Fields can't be located in lines which couldn't be parsed, so parameters and patterns are redacted anywhere.
+	redactor := rules.For("/cloudfront/example", "logs/example/E38J4Y0L8GXH9D.2020-06-08-07.d51ccc94.gz")
+	line := redactor.RedactLine("2020-06-18	03:38:13	/search	q=shoes&token=abc123&Email=someone@example.com	SESSID=abc;%20theme=dark")
+	assert.Equal(t, "2020-06-18	03:38:13	/search	q=shoes&token=REDACTED&Email=[email]	SESSID=REDACTED;%20theme=dark", line)
+	assert.Equal(t, map[string]int{"cookies": 2, "emails": 1}, redactor.Counts())
+
+	// Lines without sensitive values are left alone.
+	assert.Equal(t, "not a log line", redactor.RedactLine("not a log line"))
+}
+
+func TestParse_Errors(t *testing.T) {
+	_, err := Parse([]byte(`[{action: drop}]`))
+	assert.ErrorContains(t, err, "rule-1 drops no fields")
+
+	_, err = Parse([]byte(`[{name: tokens, action: mask}]`))
+	assert.ErrorContains(t, err, "tokens masks no parameters")
+
+	_, err = Parse([]byte(`[{action: replace, pattern: "("}]`))
+	assert.ErrorContains(t, err, "invalid pattern")
+
+	_, err = Parse([]byte(`[{action: hide}]`))
+	assert.ErrorContains(t, err, "unsupported action: hide")
+
+	_, err = Parse([]byte(`{`))
+	assert.Error(t, err)
+
+	_, err = Load("testdata/missing.yaml")
+	assert.Error(t, err)
+}
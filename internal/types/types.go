@@ -3,7 +3,9 @@ package types
 import (
 	"context"
 
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 // CloudwatchLogsInterface provides an interface for the cloudwatch logs cwLogsClient.
@@ -12,3 +14,9 @@ type CloudwatchLogsInterface interface {
 	CreateLogStream(ctx context.Context, params *cloudwatchlogs.CreateLogStreamInput, optFns ...func(options *cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogStreamOutput, error)
 	PutLogEvents(ctx context.Context, params *cloudwatchlogs.PutLogEventsInput, optFns ...func(options *cloudwatchlogs.Options)) (*cloudwatchlogs.PutLogEventsOutput, error)
 }
+
+// S3Interface provides an interface for the s3 client.
+type S3Interface interface {
+	manager.DownloadAPIClient
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(options *s3.Options)) (*s3.PutObjectOutput, error)
+}
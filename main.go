@@ -3,35 +3,85 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
-	"strconv"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws/retry"
-	"github.com/aws/aws-sdk-go-v2/config"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/config"
 	"github.com/skpr/cloudfront-cloudwatchlogs/internal/handler"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/ledger"
+	"github.com/skpr/cloudfront-cloudwatchlogs/internal/notification"
 )
 
 const (
-	// defaultBatchSize is the default batch size
-	defaultBatchSize = 1024
+	// EventSourceKinesis handles CloudFront real-time logs from a Kinesis data stream.
+	EventSourceKinesis = "kinesis"
+	// EventSourceSQS handles s3 notifications from an SQS queue, reporting the messages which failed.
+	EventSourceSQS = "sqs"
 )
 
+// eventHandler is created once when the function starts, so the databases, rules and caches it loads are reused by
+// every invocation of a warm function.
+var eventHandler *handler.EventHandler
+
 func main() {
-	lambda.Start(HandleEvents)
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+	var err error
+
+	eventHandler, err = newEventHandler(context.Background(), logger)
+	if err != nil {
+		logger.Error("Failed to create event handler", "error", err)
+		os.Exit(1)
+	}
+
+	switch os.Getenv("EVENT_SOURCE") {
+	case EventSourceKinesis:
+		lambda.Start(HandleKinesisEvents)
+	case EventSourceSQS:
+		lambda.Start(HandleSQSEvents)
+	default:
+		lambda.Start(HandleEvents)
+	}
 }
 
-// HandleEvents sent from AWS S3.
-func HandleEvents(ctx context.Context, event events.SNSEvent) error {
-	cfg, err := config.LoadDefaultConfig(ctx)
+// HandleEvents sent from AWS S3, either directly, via EventBridge, or wrapped by SNS or SQS.
+func HandleEvents(ctx context.Context, payload json.RawMessage) error {
+	// Records which can't be parsed don't stop the others from being processed.
+	records, parseErr := notification.Records(payload)
+
+	for _, record := range records {
+		fmt.Printf("[%s - %s] Bucket = %s, Key = %s \n", record.EventSource, record.EventTime, record.S3.Bucket.Name, record.S3.Object.Key)
+	}
+
+	return errors.Join(parseErr, eventHandler.HandleRecords(ctx, records))
+}
+
+// HandleSQSEvents sent from AWS S3 via an SQS queue, returning the messages which failed so only they are retried.
+func HandleSQSEvents(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	return eventHandler.HandleSQSEvent(ctx, event), nil
+}
+
+// HandleKinesisEvents sent from a CloudFront real-time log configuration, returning the record which failed so it and
+// the records after it are retried.
+func HandleKinesisEvents(ctx context.Context, event events.KinesisEvent) (events.KinesisEventResponse, error) {
+	return eventHandler.HandleKinesisEvent(ctx, event)
+}
+
+// newEventHandler creates an event handler from the environment.
+func newEventHandler(ctx context.Context, logger *slog.Logger) (*handler.EventHandler, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to setup client: %d", err)
+		return nil, fmt.Errorf("failed to setup client: %d", err)
 	}
 
 	s3Client := s3.NewFromConfig(cfg)
@@ -43,42 +93,16 @@ func HandleEvents(ctx context.Context, event events.SNSEvent) error {
 		options.Retryer = retry.AddWithMaxAttempts(options.Retryer, 0)
 	})
 
-	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
-
-	batchSize, err := getBatchSize()
+	handlerConfig, err := config.Load()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	eventHandler := handler.NewEventHandler(logger, s3Client, cwLogsClient, batchSize)
-
-	for _, r := range event.Records {
-		var event events.S3Event
-
-		if err := json.Unmarshal([]byte(r.SNS.Message), &event); err != nil {
-			return err
-		}
-
-		for _, record := range event.Records {
-			fmt.Printf("[%s - %s] Bucket = %s, Key = %s \n", record.EventSource, record.EventTime, record.S3.Bucket.Name, record.S3.Object.Key)
-
-			err := eventHandler.HandleEvent(ctx, record)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
-}
-
-// getBatchSize gets the batch size.
-func getBatchSize() (int, error) {
-	batchSizeEnv := os.Getenv("BATCH_SIZE")
+	var objectLedger ledger.Ledger
 
-	if batchSizeEnv != "" {
-		return strconv.Atoi(batchSizeEnv)
+	if handlerConfig.LedgerTable != "" {
+		objectLedger = ledger.NewDynamoDB(dynamodb.NewFromConfig(cfg), handlerConfig.LedgerTable)
 	}
 
-	return defaultBatchSize, nil
+	return handler.NewEventHandler(logger, s3Client, cwLogsClient, objectLedger, handlerConfig)
 }